@@ -0,0 +1,90 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// 產生帳號啟用權杖(綁定電子郵件，變更電子郵件後失效)
+func setActivationToken(account *model.Account) string {
+	now := utils.GetCurrentTime()
+	claims := jwt.MapClaims{
+		"id":    account.ID,
+		"email": account.Email,
+		"typ":   "activation",
+		"iss":   configuration.JWTIssuer,
+		"aud":   configuration.JWTAudience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(configuration.ActivationLinkExpireDuration).Unix(),
+	}
+	signedToken, _ := helper.SignToken(claims)
+
+	return signedToken
+}
+
+// 寄送帳號啟用連結
+func sendActivationMail(account *model.Account) error {
+	link := fmt.Sprintf("%s/activate?token=%s", configuration.SiteURL, url.QueryEscape(setActivationToken(account)))
+	body := fmt.Sprintf("請點擊以下連結啟用帳號，連結將於 %d 小時後失效：\n\n%s", int(configuration.ActivationLinkExpireDuration.Hours()), link)
+
+	return helper.Mailer.Send(account.Email, "Jimandy 帳號啟用", body)
+}
+
+// 以電子郵件驗證連結啟用帳號
+func ActivateAccount(context *fiber.Ctx) error {
+	var myMagicLink data.MagicLink
+	_ = context.BodyParser(&myMagicLink)
+
+	if myMagicLink.Token == "" {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	claims, ok := parseSignedToken(myMagicLink.Token, "activation")
+	if !ok {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	id, _ := claims["id"].(float64)
+	email, _ := claims["email"].(string)
+	if !database.ActivateAccount(uint(id), email) {
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	return context.SendStatus(fiber.StatusOK)
+}
+
+// 重新寄送帳號啟用連結
+func ResendActivation(context *fiber.Ctx) error {
+	var myLoginData data.Login
+	_ = context.BodyParser(&myLoginData)
+
+	if myLoginData.Email == "" {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// 若 超過每帳號寄送次數 則 回請求過多
+	if !AllowRequest(context, "acct:"+strings.ToLower(myLoginData.Email), configuration.RateLimitPerAccount) {
+		return context.SendStatus(fiber.StatusTooManyRequests)
+	}
+
+	account := database.GetAccountByEmail(myLoginData.Email)
+	if account.ID != 0 && account.Status == model.UnActive {
+		if err := sendActivationMail(&account); err != nil {
+			return context.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+
+	// 不論帳號是否存在皆回傳成功，避免洩漏帳號資訊
+	return context.SendStatus(fiber.StatusOK)
+}
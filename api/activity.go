@@ -16,14 +16,26 @@ var activityCache = cache.New(30*time.Minute, 60*time.Minute)
 // 取得運動員所有活動
 func GetActivities(context *fiber.Ctx) error {
 	athleteID, _ := context.ParamsInt("athleteid") // 運動員主鍵
+
+	myAthlete, access := getAthleteAccess(context, uint64(athleteID)) // 依運動員主鍵取得運動員與存取層級
+
+	// 無權存取與不存在的運動員一律回傳 404，避免洩漏運動員是否存在
+	switch access {
+	case athleteAccessNone:
+		return context.SendStatus(fiber.StatusNotFound)
+	case athleteAccessPublic:
+		return context.JSON(database.GetPublicActivitiesByAthleteID(myAthlete.ID))
+	case athleteAccessViewer:
+		return context.JSON(database.GetActivitiesByAthleteID(myAthlete.ID))
+	}
+
+	// 只有運動員本人會以自己的權杖爬取 Strava
 	cacheKey := fmt.Sprintf("activity-%d", athleteID)
 
 	if cachedData, found := activityCache.Get(cacheKey); found {
 		return context.JSON(cachedData)
 	}
 
-	myAthlete := database.GetAthleteByID(uint64(athleteID)) // 依運動員主鍵取得運動員
-
 	_ = helper.FetchStravaActivities(myAthlete)
 
 	activities := database.GetActivitiesByAthleteID(myAthlete.ID)
@@ -38,12 +50,16 @@ func GetActivityLaps(context *fiber.Ctx) error {
 	athleteID, _ := context.ParamsInt("athleteid") // 運動員主鍵
 	activityID := context.Params("activityid")     // 活動主鍵
 
-	myAthlete := database.GetAthleteByID(uint64(athleteID)) // 依運動員主鍵取得運動員
+	myAthlete, access := getAthleteAccess(context, uint64(athleteID)) // 依運動員主鍵取得運動員與存取層級
 
 	activity := database.GetActivityByID(activityID)
+	if !canAccessActivity(myAthlete, access, activity) {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
 	laps := database.GetLaps(myAthlete.ID, activityID) // 取得跑步活動圈數
-	// 若 沒有跑步圈數 則 爬取後再取得跑步活動圈數
-	if len(laps) == 0 {
+	// 若 沒有跑步圈數且為運動員本人 則 爬取後再取得跑步活動圈數
+	if len(laps) == 0 && access == athleteAccessOwner {
 		_ = helper.FetchStravaLaps(myAthlete, activityID)
 		laps = database.GetLaps(myAthlete.ID, activityID) // 取得跑步活動圈數
 	}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"fmt"
+
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 記錄管理員操作
+func recordAdminAction(context *fiber.Ctx, accountID uint, action string) {
+	recordSecurityEvent(context, accountID, model.SecurityEventAdminAction, model.SecurityOutcomeSuccess, action)
+}
+
+// 取得網址中的帳號，並檢查帳號存在
+func getTargetAccount(context *fiber.Ctx) model.Account {
+	accountID, err := context.ParamsInt("accountid")
+	if err != nil || accountID <= 0 {
+		return model.Account{}
+	}
+
+	return database.GetAccountByID(uint64(accountID))
+}
+
+// 搜尋帳號(管理員)
+// 可用條件 q(名稱或電子郵件)、status
+func GetAccounts(context *fiber.Ctx) error {
+	page, pageSize := getPagination(context)
+
+	var status *int8
+	if context.Query("status") != "" {
+		value := int8(context.QueryInt("status"))
+		status = &value
+	}
+
+	accounts, total := database.SearchAccounts(context.Query("q"), status, (page-1)*pageSize, pageSize)
+
+	recordAdminAction(context, 0, fmt.Sprintf("search accounts q=%q status=%s", context.Query("q"), context.Query("status")))
+
+	return context.JSON(paginated(accounts, total, page, pageSize))
+}
+
+// 取得帳號詳細資料、連結的運動員與登入中的裝置(管理員)
+func GetAccount(context *fiber.Ctx) error {
+	account := getTargetAccount(context)
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	// 運動員不回傳 Strava 憑證
+	athlete := database.GetAthleteByAccountID(account.ID).Public()
+
+	sessions := []fiber.Map{}
+	for _, session := range database.GetAccountSessions(account.ID, utils.GetCurrentTime()) {
+		sessions = append(sessions, fiber.Map{
+			"sessionId":   session.ID,
+			"name":        session.Name,
+			"browser":     session.Browser,
+			"os":          session.OS,
+			"device":      session.Device,
+			"ip":          session.LastIP,
+			"firstSeenAt": session.FirstSeenAt,
+			"lastSeenAt":  session.LastSeenAt,
+		})
+	}
+
+	recordAdminAction(context, account.ID, "view account")
+
+	return context.JSON(fiber.Map{
+		"account":  account,
+		"athlete":  athlete,
+		"sessions": sessions,
+	})
+}
+
+// 停用或啟用帳號(管理員)，停用時撤銷所有工作階段
+func SetAccountStatus(context *fiber.Ctx) error {
+	account := getTargetAccount(context)
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	// 若 內容無法解析或未提供狀態 則 回錯誤，避免空內容被當成停用
+	var myStatus data.AccountStatus
+	if err := context.BodyParser(&myStatus); err != nil || myStatus.Status == nil {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// 不可停用自己的帳號
+	if account.ID == uint(context.Locals("id").(float64)) {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	switch *myStatus.Status {
+	case model.Disabled:
+		if !database.DisableAccount(account.ID) {
+			return context.SendStatus(fiber.StatusInternalServerError)
+		}
+		recordAdminAction(context, account.ID, "disable account")
+	case model.Enabled:
+		// 只有已停用的帳號可重新啟用
+		if !database.EnableAccount(account.ID) {
+			return context.SendStatus(fiber.StatusConflict)
+		}
+		recordAdminAction(context, account.ID, "enable account")
+	default:
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	return context.SendStatus(fiber.StatusOK)
+}
+
+// 強制登出帳號的所有工作階段(管理員)
+func LogoutAccount(context *fiber.Ctx) error {
+	account := getTargetAccount(context)
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	if !database.RevokeAllUserTokens(account.ID) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	recordAdminAction(context, account.ID, "logout all sessions")
+
+	return context.SendStatus(fiber.StatusOK)
+}
+
+// 設定或取消帳號的管理員身分(管理員)
+func SetAccountAdmin(context *fiber.Ctx) error {
+	account := getTargetAccount(context)
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	// 若 內容無法解析或未提供管理員身分 則 回錯誤
+	var myAdmin data.AccountAdmin
+	if err := context.BodyParser(&myAdmin); err != nil || myAdmin.IsAdmin == nil {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+	isAdmin := *myAdmin.IsAdmin
+
+	// 不可取消自己的管理員身分，避免沒有管理員
+	if !isAdmin && account.ID == uint(context.Locals("id").(float64)) {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if !database.SetAccountAdmin(account.ID, isAdmin) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	ClearPermissionCache(account.ID)
+	recordAdminAction(context, account.ID, fmt.Sprintf("set admin %t", isAdmin))
+
+	return context.SendStatus(fiber.StatusOK)
+}
+
+// 取得 Strava API 配額使用狀況(管理員)
+func GetStravaRateLimit(context *fiber.Ctx) error {
+	return context.JSON(helper.GetStravaRateLimitUsage())
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestSetAccountStatus(t *testing.T) {
+	app := fiber.New()
+	app.Put("/api/admin/accounts/:accountid/status", func(context *fiber.Ctx) error {
+		context.Locals("id", float64(0)) // 管理員帳號
+		return context.Next()
+	}, SetAccountStatus)
+
+	enabled := model.Account{Name: "Enabled", Email: "status-enabled@example.com", Status: model.Enabled}
+	inactive := model.Account{Name: "Inactive", Email: "status-inactive@example.com", Status: model.UnActive}
+	if !database.AddAccount(&enabled) || !database.AddAccount(&inactive) {
+		t.Fatal("add accounts")
+	}
+
+	setStatus := func(account model.Account, status int8) int {
+		code, _ := sendJSON(t, app, fiber.MethodPut, fmt.Sprintf("/api/admin/accounts/%d/status", account.ID), fiber.Map{"Status": status})
+		return code
+	}
+
+	tests := []struct {
+		name    string
+		account model.Account
+		status  int8
+		code    int
+		want    int8
+	}{
+		{"enable enabled", enabled, model.Enabled, fiber.StatusConflict, model.Enabled},
+		{"enable inactive", inactive, model.Enabled, fiber.StatusConflict, model.UnActive},
+		{"disable enabled", enabled, model.Disabled, fiber.StatusOK, model.Disabled},
+		{"enable disabled", enabled, model.Enabled, fiber.StatusOK, model.Enabled},
+	}
+
+	for _, test := range tests {
+		if code := setStatus(test.account, test.status); code != test.code {
+			t.Fatalf("%s: status %d, want %d", test.name, code, test.code)
+		}
+		if account := database.GetAccountByID(uint64(test.account.ID)); account.Status != test.want {
+			t.Fatalf("%s: account status %d, want %d", test.name, account.Status, test.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 驗證個人存取金鑰，成功時設定與 access token 相同的登入資訊及金鑰的授權範圍
+func AuthenticateAPIKey(context *fiber.Ctx) bool {
+	apiKey := database.GetAPIKeyByHash(database.HashToken(GetTokenFromHeader(context)))
+	if apiKey == nil {
+		return false
+	}
+
+	now := utils.GetCurrentTime()
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return false
+	}
+
+	// 停用帳號的金鑰一併失效
+	if account := database.GetAccountByID(uint64(apiKey.AccountID)); !isAccountEnabled(&account) {
+		return false
+	}
+
+	database.TouchAPIKey(apiKey.ID, context.IP(), now)
+
+	context.Locals("id", float64(apiKey.AccountID)) // 登入帳號
+	context.Locals("scopes", apiKeyScopes(apiKey))  // 授權範圍
+
+	return true
+}
+
+// 檢查個人存取金鑰是否擁有指定授權範圍，以登入工作階段呼叫時不受限制
+func HasScope(context *fiber.Ctx, permission string) bool {
+	scopes, ok := context.Locals("scopes").([]string)
+	return !ok || slices.Contains(scopes, permission)
+}
+
+// 取得個人存取金鑰的授權範圍
+func apiKeyScopes(apiKey *model.APIKey) []string {
+	if apiKey.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(apiKey.Scopes, ",")
+}
+
+// 取得當前使用者的個人存取金鑰
+func GetAPIKeys(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+
+	apiKeys := []fiber.Map{}
+	for _, apiKey := range database.GetAccountAPIKeys(accountID) {
+		apiKeys = append(apiKeys, fiber.Map{
+			"id":         apiKey.ID,
+			"name":       apiKey.Name,
+			"prefix":     apiKey.Prefix,
+			"scopes":     apiKeyScopes(&apiKey),
+			"createdAt":  apiKey.CreatedAt,
+			"expiresAt":  apiKey.ExpiresAt,
+			"lastUsedAt": apiKey.LastUsedAt,
+			"lastUsedIp": apiKey.LastUsedIP,
+		})
+	}
+
+	return context.JSON(apiKeys)
+}
+
+// 建立個人存取金鑰，金鑰只在建立時回傳一次
+func AddAPIKey(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+
+	var myAPIKey data.APIKey
+	_ = context.BodyParser(&myAPIKey)
+
+	if myAPIKey.Name == "" || len(myAPIKey.Name) > 64 || len(myAPIKey.Scopes) == 0 {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	now := utils.GetCurrentTime()
+	if myAPIKey.ExpiresAt != nil && !myAPIKey.ExpiresAt.After(now) {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// 授權範圍不可超過帳號目前擁有的權限
+	myPermissions := getAccountPermissions(accountID).Permissions
+	scopes := []string{}
+	for _, scope := range myAPIKey.Scopes {
+		if !myPermissions[scope] {
+			return context.SendStatus(fiber.StatusForbidden)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	rawKey := model.APIKeyPrefix + generateRandomString(24)
+	apiKey := model.APIKey{
+		AccountID: accountID,
+		Name:      myAPIKey.Name,
+		Prefix:    rawKey[:len(model.APIKeyPrefix)+8],
+		KeyHash:   database.HashToken(rawKey),
+		Scopes:    strings.Join(scopes, ","),
+		CreatedAt: now,
+		ExpiresAt: myAPIKey.ExpiresAt,
+	}
+	if !database.AddAPIKey(&apiKey) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return context.JSON(fiber.Map{
+		"id":        apiKey.ID,
+		"name":      apiKey.Name,
+		"key":       rawKey,
+		"scopes":    scopes,
+		"expiresAt": apiKey.ExpiresAt,
+	})
+}
+
+// 撤銷個人存取金鑰
+func DeleteAPIKey(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+	id, err := strconv.ParseUint(context.Params("id"), 10, 64)
+	if err != nil {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if !database.RevokeAPIKey(accountID, uint(id), utils.GetCurrentTime()) {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	return context.SendStatus(fiber.StatusOK)
+}
@@ -1,25 +1,88 @@
 package api
 
 import (
-	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
 	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func AddAthlete(context *fiber.Ctx) error {
-	accountID := context.Locals("id").(float64) // 登入帳號主鍵
+// 取得授權檢視活動的帳號
+func GetAthleteViewers(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64)) // 登入帳號主鍵
 
-	var myAthlete model.Athlete
-	_ = context.BodyParser(&myAthlete)
+	myAthlete := database.GetAthleteByAccountID(accountID)
+	if myAthlete.ID == 0 {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	viewers := []fiber.Map{}
+	for _, account := range database.GetAthleteViewers(myAthlete.ID) {
+		viewers = append(viewers, fiber.Map{"id": account.ID, "name": account.Name, "email": account.Email})
+	}
+
+	return context.JSON(viewers)
+}
+
+// 授權帳號檢視活動
+func AddAthleteViewer(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64)) // 登入帳號主鍵
+
+	var myViewer data.AthleteViewer
+	_ = context.BodyParser(&myViewer)
+
+	myAthlete := database.GetAthleteByAccountID(accountID)
+	if myAthlete.ID == 0 {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	viewerAccount := database.GetAccountByEmail(myViewer.Email)
+	if myViewer.Email == "" || viewerAccount.ID == 0 || viewerAccount.ID == accountID {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	viewer := model.AthleteViewer{AthleteID: myAthlete.ID, AccountID: viewerAccount.ID, CreatedAt: utils.GetCurrentTime()}
+	if !database.AddAthleteViewer(&viewer) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
 
-	myAthlete.AccountID = uint(accountID)
+	return context.SendStatus(fiber.StatusOK)
+}
+
+// 取消帳號檢視活動的授權
+func DeleteAthleteViewer(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64)) // 登入帳號主鍵
+	viewerAccountID, _ := context.ParamsInt("accountid")
+
+	myAthlete := database.GetAthleteByAccountID(accountID)
+	if myAthlete.ID == 0 {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	if !database.DeleteAthleteViewer(myAthlete.ID, uint(viewerAccountID)) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return context.SendStatus(fiber.StatusOK)
+}
+
+// 設定是否公開活動
+func SetAthleteVisibility(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64)) // 登入帳號主鍵
+
+	var myVisibility data.AthleteVisibility
+	_ = context.BodyParser(&myVisibility)
+
+	myAthlete := database.GetAthleteByAccountID(accountID)
+	if myAthlete.ID == 0 {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
 
-	// 若 授權Strava成功 則 回傳成功
-	if helper.AuthorizeStrava(myAthlete) {
-		return context.SendStatus(fiber.StatusOK)
+	if !database.UpdateAthleteVisibility(myAthlete.ID, myVisibility.IsPublic) {
+		return context.SendStatus(fiber.StatusInternalServerError)
 	}
 
-	// 否則 回傳錯誤
-	return context.SendStatus(fiber.StatusInternalServerError)
+	return context.SendStatus(fiber.StatusOK)
 }
@@ -3,12 +3,14 @@ package api
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"strings"
 	"time"
 
 	"Jimandy-Website-Backend/configuration"
 	"Jimandy-Website-Backend/data"
 	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
 	"Jimandy-Website-Backend/model"
 	"Jimandy-Website-Backend/utils"
 
@@ -16,12 +18,6 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var (
-	// 權杖預設逾時
-	accessTokenExpireDuration  = time.Hour * 24 * 7      // 1小時
-	refreshTokenExpireDuration = time.Hour * 24 * 7 * 30 // 一個月
-)
-
 // 產生隨機字串
 func generateRandomString(length int) string {
 	bytes := make([]byte, length)
@@ -29,11 +25,24 @@ func generateRandomString(length int) string {
 	return hex.EncodeToString(bytes)
 }
 
-// 取得裝置資訊
-func getDeviceInfo(context *fiber.Ctx) string {
-	userAgent := context.Get("User-Agent")
-	ip := context.IP()
-	return userAgent + "|" + ip
+// 帳號是否可登入(停用與尚未啟用的帳號不可登入)
+func isAccountEnabled(account *model.Account) bool {
+	return account.Status == model.Enabled
+}
+
+// 解析本服務簽發的權杖並檢查類型
+func parseSignedToken(signedToken string, tokenType string) (jwt.MapClaims, bool) {
+	token, err := jwt.Parse(signedToken, helper.TokenKeyFunc)
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != tokenType {
+		return nil, false
+	}
+
+	return claims, true
 }
 
 // 從 Authorization header 取得 token
@@ -49,178 +58,245 @@ func GetTokenFromHeader(context *fiber.Ctx) string {
 	return token
 }
 
+// 以電子郵件與密碼登入
 func Login(context *fiber.Ctx) error {
 	var myLoginData data.Login
 	_ = context.BodyParser(&myLoginData)
 
-	// 若 沒Email 則 回錯誤
-	if myLoginData.Email == "" {
+	// 若 沒Email或密碼 則 回錯誤
+	if myLoginData.Email == "" || myLoginData.Password == "" {
 		return context.SendStatus(fiber.StatusBadRequest)
 	}
 
 	account := database.GetAccountByEmail(myLoginData.Email) // 依 登入帳號 取得 帳號
-	if account.ID == 0 {
-		account = model.Account{
-			Name:  myLoginData.Name,
-			Email: myLoginData.Email,
-		}
-		if !database.AddAccount(account) {
-			return context.SendStatus(fiber.StatusBadRequest)
-		}
+
+	// 若 超過每帳號呼叫次數 或 帳號因連續登入失敗而鎖定 則 回請求過多
+	// 驗證密碼前先記錄一次失敗，成功後再清除，同時送出的請求無法繞過鎖定
+	if !AllowRequest(context, "acct:"+strings.ToLower(myLoginData.Email), configuration.RateLimitPerAccount) || !beginLoginAttempt(context, myLoginData.Email) {
+		recordSecurityEvent(context, account.ID, model.SecurityEventLogin, model.SecurityOutcomeFailure, "password locked "+myLoginData.Email)
+		return context.SendStatus(fiber.StatusTooManyRequests)
 	}
 
-	return context.JSON(GenerateTokens(&account, context))
+	// 若 帳號不存在或密碼錯誤 則 保留失敗紀錄並回未授權
+	if !utils.CheckPassword(account.PasswordHash, myLoginData.Password) || account.ID == 0 {
+		recordSecurityEvent(context, account.ID, model.SecurityEventLogin, model.SecurityOutcomeFailure, "password "+myLoginData.Email)
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	helper.Limiter.ResetFailures(loginFailureKey(myLoginData.Email))
+
+	return completeLogin(context, &account, "password")
 }
 
-func setAccessToken(account *model.Account) string {
+// 以電子郵件與密碼註冊帳號
+func Register(context *fiber.Ctx) error {
+	var myLoginData data.Login
+	_ = context.BodyParser(&myLoginData)
+
+	// 若 沒Email或密碼長度不符 則 回錯誤
+	if myLoginData.Email == "" || len(myLoginData.Password) < utils.MinPasswordLength || len(myLoginData.Password) > 72 {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// 若 帳號已存在 則 回衝突
+	if database.GetAccountByEmail(myLoginData.Email).ID != 0 {
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	passwordHash, err := utils.HashPassword(myLoginData.Password)
+	if err != nil {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// 新帳號須先以電子郵件驗證連結啟用
+	account := model.Account{
+		Name:         myLoginData.Name,
+		Email:        myLoginData.Email,
+		PasswordHash: passwordHash,
+		Status:       model.UnActive,
+	}
+	if !database.AddAccount(&account) {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if err := sendActivationMail(&account); err != nil {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return context.SendStatus(fiber.StatusCreated)
+}
+
+func setAccessToken(account *model.Account, sessionID string, expiresAt time.Time) string {
 	now := utils.GetCurrentTime()
 	// 產生 access token
 	accessClaims := jwt.MapClaims{
-		"id":  account.ID,
-		"exp": now.Add(accessTokenExpireDuration).Unix(),
-		"jti": generateRandomString(16), // 加入隨機字串作為 JWT ID
+		"id":     account.ID,
+		"sid":    sessionID,
+		"typ":    "access",
+		"iss":    configuration.JWTIssuer,
+		"aud":    configuration.JWTAudience,
+		"iat":    now.Unix(),
+		"iat_ms": now.UnixMilli(), // 簽發時間(毫秒)，用於判斷是否早於撤銷時間
+		"nbf":    now.Unix(),
+		"exp":    expiresAt.Unix(),
+		"jti":    generateRandomString(16), // 加入隨機字串作為 JWT ID
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	signedAccessToken, _ := accessToken.SignedString(configuration.JWTKey)
+	signedAccessToken, _ := helper.SignToken(accessClaims)
 
 	return signedAccessToken
 }
 
-func setRefreshToken(account *model.Account) string {
+func setRefreshToken(account *model.Account, expiresAt time.Time) string {
 	now := utils.GetCurrentTime()
 	// 產生 refresh token
 	refreshClaims := jwt.MapClaims{
 		"id":  account.ID,
-		"exp": now.Add(refreshTokenExpireDuration).Unix(),
+		"typ": "refresh",
+		"iss": configuration.JWTIssuer,
+		"aud": configuration.JWTAudience,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": expiresAt.Unix(),
 		"jti": generateRandomString(16), // 加入隨機字串作為 JWT ID
 	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	signedRefreshToken, _ := refreshToken.SignedString(configuration.JWTKey)
+	signedRefreshToken, _ := helper.SignToken(refreshClaims)
 
 	return signedRefreshToken
 }
 
-// 產生權杖
-func GenerateTokens(account *model.Account, context *fiber.Ctx) fiber.Map {
-	deviceInfo := getDeviceInfo(context)
+// 取較早的時間
+func earlierTime(a time.Time, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+// 建立新的 token 資料(尚未儲存)，並更新工作階段最後使用資訊
+// 資料庫只保存權杖雜湊，權杖明碼只回傳給用戶端
+// refresh token 逾時每次刷新重新計算(滑動)，但不超過閒置逾時與登入最長存活時間
+func newToken(account *model.Account, context *fiber.Ctx, session *model.Session) (model.Token, fiber.Map) {
 	now := utils.GetCurrentTime()
 
-	// 產生 tokens
-	accessToken := setAccessToken(account)
-	refreshToken := setRefreshToken(account)
+	refreshExpiresAt := now.Add(configuration.RefreshTokenExpireDuration)
+	if configuration.SessionIdleTimeout > 0 {
+		refreshExpiresAt = earlierTime(refreshExpiresAt, now.Add(configuration.SessionIdleTimeout))
+	}
+	if configuration.SessionMaxAge > 0 {
+		refreshExpiresAt = earlierTime(refreshExpiresAt, session.FirstSeenAt.Add(configuration.SessionMaxAge))
+	}
+	accessExpiresAt := earlierTime(now.Add(configuration.AccessTokenExpireDuration), refreshExpiresAt)
+
+	session.UserAgent = context.Get("User-Agent")
+	session.LastIP = context.IP()
+	session.LastSeenAt = now
+	session.ExpiresAt = refreshExpiresAt
+
+	accessToken := setAccessToken(account, session.ID, accessExpiresAt)
+	refreshToken := setRefreshToken(account, refreshExpiresAt)
 
-	// 儲存 tokens 到資料庫
 	myToken := model.Token{
-		AccountID:    account.ID,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		DeviceInfo:   deviceInfo,
-		CreatedAt:    now,
-		ExpiresAt:    now.Add(accessTokenExpireDuration),
+		AccountID:        account.ID,
+		SessionID:        session.ID,
+		AccessTokenHash:  database.HashToken(accessToken),
+		RefreshTokenHash: database.HashToken(refreshToken),
+		CreatedAt:        now,
+		ExpiresAt:        accessExpiresAt,
+		RefreshExpiresAt: refreshExpiresAt,
 	}
 
-	if database.SaveTokens(&myToken) {
-		return fiber.Map{
-			"accessToken":  accessToken,
-			"refreshToken": refreshToken,
-		}
+	return myToken, fiber.Map{
+		"accessToken":  accessToken,
+		"refreshToken": refreshToken,
 	}
-	return fiber.Map{}
 }
 
-// 刷新 access token
-func RefreshToken(context *fiber.Ctx) error {
-	refreshToken := GetTokenFromHeader(context)
-	if refreshToken == "" {
-		return context.SendStatus(fiber.StatusUnauthorized)
-	}
+// 產生權杖，每次登入為新的工作階段
+func GenerateTokens(account *model.Account, context *fiber.Ctx) fiber.Map {
+	session := newSession(account, context)
+	myToken, tokens := newToken(account, context, &session)
 
-	// 檢查 refresh token 是否有效
-	dbToken := database.GetTokenByRefreshToken(refreshToken)
-	if dbToken == nil || dbToken.IsRevoked || utils.GetCurrentTime().After(dbToken.ExpiresAt) {
-		return context.SendStatus(fiber.StatusUnauthorized)
+	if database.AddSession(&session, &myToken) {
+		return tokens
 	}
+	return fiber.Map{}
+}
 
-	// 獲取用戶信息
-	account := database.GetAccountByID(uint64(dbToken.AccountID))
-	if account.ID == 0 {
-		return context.SendStatus(fiber.StatusUnauthorized)
+// 記錄安全事件，執行者為登入帳號(未登入時為事件所屬帳號)
+func recordSecurityEvent(context *fiber.Ctx, accountID uint, eventType string, outcome string, detail string) {
+	actorID := accountID
+	if id, ok := context.Locals("id").(float64); ok {
+		actorID = uint(id)
 	}
 
-	// 生成新的 access token
-	signedAccessToken := setAccessToken(&account)
-	dbToken.AccessToken = signedAccessToken
-	database.SaveTokens(dbToken)
-
-	return context.JSON(fiber.Map{
-		"accessToken": signedAccessToken,
+	_ = database.AddSecurityEvent(&model.SecurityEvent{
+		AccountID: accountID,
+		ActorID:   actorID,
+		Type:      eventType,
+		Outcome:   outcome,
+		IP:        context.IP(),
+		UserAgent: context.Get("User-Agent"),
+		Detail:    detail,
+		CreatedAt: utils.GetCurrentTime(),
 	})
 }
 
-// 登出
-func Logout(context *fiber.Ctx) error {
+// 刷新權杖，每次刷新都換發新的 refresh token
+func RefreshToken(context *fiber.Ctx) error {
 	refreshToken := GetTokenFromHeader(context)
 	if refreshToken == "" {
 		return context.SendStatus(fiber.StatusUnauthorized)
 	}
 
-	// 檢查 refresh token 是否有效
 	dbToken := database.GetTokenByRefreshToken(refreshToken)
-	if dbToken == nil || dbToken.IsRevoked {
+	if dbToken == nil {
 		return context.SendStatus(fiber.StatusUnauthorized)
 	}
 
-	// 撤銷當前的 refresh token
-	dbToken.IsRevoked = true
-	database.SaveTokens(dbToken)
-
-	// 撤銷對應的 access token
-	accessTokens := database.GetUserTokens(dbToken.AccountID)
-	for _, token := range accessTokens {
-		if token.DeviceInfo == dbToken.DeviceInfo {
-			token.IsRevoked = true
-			database.SaveTokens(&token)
-		}
+	// 若 超過每帳號呼叫次數 則 回請求過多
+	if !AllowRequest(context, fmt.Sprintf("acct-id:%d", dbToken.AccountID), configuration.RateLimitPerAccount) {
+		return context.SendStatus(fiber.StatusTooManyRequests)
 	}
 
-	return context.SendStatus(fiber.StatusOK)
-}
-
-// 檢查 token 是否有效
-func IsTokenValid(token string) bool {
-	if token == "" {
-		return false
+	// 若 refresh token 已被換發過 則 視為遭竊取，撤銷整個工作階段
+	if dbToken.RotatedAt != nil {
+		database.RevokeSession(dbToken.SessionID, utils.GetCurrentTime())
+		recordSecurityEvent(context, dbToken.AccountID, model.SecurityEventRefreshTokenReuse, model.SecurityOutcomeFailure, "session "+dbToken.SessionID)
+		return context.SendStatus(fiber.StatusUnauthorized)
 	}
 
-	// 檢查 token 是否在資料庫中且未撤銷
-	dbToken := database.GetTokenByAccessToken(token)
-	if dbToken == nil || dbToken.IsRevoked || time.Now().After(dbToken.ExpiresAt) {
-		return false
+	// 檢查 refresh token 是否有效
+	if dbToken.IsRevoked || utils.GetCurrentTime().After(dbToken.RefreshExpiresAt) {
+		recordSecurityEvent(context, dbToken.AccountID, model.SecurityEventTokenRefresh, model.SecurityOutcomeFailure, "session "+dbToken.SessionID)
+		return context.SendStatus(fiber.StatusUnauthorized)
 	}
 
-	return true
-}
+	// 獲取用戶信息，停用的帳號不可刷新
+	account := database.GetAccountByID(uint64(dbToken.AccountID))
+	if account.ID == 0 || !isAccountEnabled(&account) {
+		recordSecurityEvent(context, dbToken.AccountID, model.SecurityEventTokenRefresh, model.SecurityOutcomeFailure, "account disabled")
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
 
-// 取得用戶的所有裝置
-func GetUserDevices(context *fiber.Ctx) error {
-	accountID := uint(context.Locals("id").(float64))
-	devices := database.GetUserTokens(accountID)
+	session := database.GetSessionByID(dbToken.SessionID)
+	if session == nil || session.RevokedAt != nil {
+		recordSecurityEvent(context, dbToken.AccountID, model.SecurityEventTokenRefresh, model.SecurityOutcomeFailure, "session "+dbToken.SessionID)
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
 
-	var deviceList []fiber.Map
-	for _, token := range devices {
-		deviceInfo := strings.Split(token.DeviceInfo, "|")
-		deviceList = append(deviceList, fiber.Map{
-			"deviceInfo": deviceInfo[0],
-			"ip":         deviceInfo[1],
-			"lastLogin":  token.CreatedAt,
-		})
+	// 換發新的 access token 與 refresh token，沿用同一工作階段
+	myToken, tokens := newToken(&account, context, session)
+	if !database.RotateTokens(dbToken, &myToken, session) {
+		return context.SendStatus(fiber.StatusUnauthorized)
 	}
 
-	return context.JSON(deviceList)
+	recordSecurityEvent(context, account.ID, model.SecurityEventTokenRefresh, model.SecurityOutcomeSuccess, "session "+session.ID)
+
+	return context.JSON(tokens)
 }
 
-// 登出特定裝置
-func LogoutDevice(context *fiber.Ctx) error {
+// 登出
+func Logout(context *fiber.Ctx) error {
 	refreshToken := GetTokenFromHeader(context)
 	if refreshToken == "" {
 		return context.SendStatus(fiber.StatusUnauthorized)
@@ -228,20 +304,19 @@ func LogoutDevice(context *fiber.Ctx) error {
 
 	// 檢查 refresh token 是否有效
 	dbToken := database.GetTokenByRefreshToken(refreshToken)
-	if dbToken == nil || dbToken.IsRevoked || time.Now().After(dbToken.ExpiresAt) {
+	if dbToken == nil || dbToken.IsRevoked || dbToken.RotatedAt != nil {
 		return context.SendStatus(fiber.StatusUnauthorized)
 	}
 
-	deviceInfo := getDeviceInfo(context)
-
-	// 撤銷指定裝置的所有 token
-	tokens := database.GetUserTokens(dbToken.AccountID)
-	for _, token := range tokens {
-		if token.DeviceInfo == deviceInfo {
-			token.IsRevoked = true
-			database.SaveTokens(&token)
-		}
+	// 若 超過每帳號呼叫次數 則 回請求過多
+	if !AllowRequest(context, fmt.Sprintf("acct-id:%d", dbToken.AccountID), configuration.RateLimitPerAccount) {
+		return context.SendStatus(fiber.StatusTooManyRequests)
 	}
 
+	// 撤銷整個工作階段
+	database.RevokeSession(dbToken.SessionID, utils.GetCurrentTime())
+
+	recordSecurityEvent(context, dbToken.AccountID, model.SecurityEventLogout, model.SecurityOutcomeSuccess, "session "+dbToken.SessionID)
+
 	return context.SendStatus(fiber.StatusOK)
 }
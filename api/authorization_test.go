@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 建立刷新權杖測試用的應用程式，/login 直接以指定帳號登入
+func newRefreshApp(account *model.Account) *fiber.App {
+	app := fiber.New()
+	app.Post("/login", func(context *fiber.Ctx) error {
+		return context.JSON(GenerateTokens(account, context))
+	})
+	app.Post("/api/refresh", RefreshToken)
+	return app
+}
+
+// 以指定的權杖送出請求並回傳狀態碼與解析後的回應
+func sendWithToken(t *testing.T, app *fiber.App, path string, token string) (int, map[string]interface{}) {
+	t.Helper()
+
+	request := httptest.NewRequest(fiber.MethodPost, path, nil)
+	request.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+
+	response, err := app.Test(request, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	result := map[string]interface{}{}
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	return response.StatusCode, result
+}
+
+// 新增啟用中的帳號並登入，回傳 refresh token
+func loginForRefresh(t *testing.T, email string) (*fiber.App, string) {
+	t.Helper()
+
+	account := model.Account{Name: "Refresh", Email: email, Status: model.Enabled}
+	if !database.AddAccount(&account) {
+		t.Fatal("add account")
+	}
+	app := newRefreshApp(&account)
+
+	status, tokens := sendJSON(t, app, fiber.MethodPost, "/login", nil)
+	refreshToken, _ := tokens["refreshToken"].(string)
+	if status != fiber.StatusOK || refreshToken == "" {
+		t.Fatalf("login: status %d, body %v", status, tokens)
+	}
+	return app, refreshToken
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	app, firstToken := loginForRefresh(t, "refresh-rotation@example.com")
+
+	status, tokens := sendWithToken(t, app, "/api/refresh", firstToken)
+	secondToken, _ := tokens["refreshToken"].(string)
+	if status != fiber.StatusOK || tokens["accessToken"] == nil || secondToken == "" || secondToken == firstToken {
+		t.Fatalf("refresh: status %d, body %v", status, tokens)
+	}
+
+	// 換發後的 refresh token 沿用同一工作階段
+	first := database.GetTokenByRefreshToken(firstToken)
+	second := database.GetTokenByRefreshToken(secondToken)
+	if first == nil || second == nil || first.RotatedAt == nil || first.SessionID != second.SessionID {
+		t.Fatalf("tokens not rotated: %+v, %+v", first, second)
+	}
+
+	if status, _ := sendWithToken(t, app, "/api/refresh", secondToken); status != fiber.StatusOK {
+		t.Fatalf("refresh with rotated token: status %d", status)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	app, firstToken := loginForRefresh(t, "refresh-reuse@example.com")
+
+	_, tokens := sendWithToken(t, app, "/api/refresh", firstToken)
+	secondToken, _ := tokens["refreshToken"].(string)
+	if secondToken == "" {
+		t.Fatalf("refresh: body %v", tokens)
+	}
+
+	// 重複使用已換發的 refresh token 視為遭竊取
+	if status, _ := sendWithToken(t, app, "/api/refresh", firstToken); status != fiber.StatusUnauthorized {
+		t.Fatalf("reused token: status %d", status)
+	}
+
+	session := database.GetSessionByID(database.GetTokenByRefreshToken(firstToken).SessionID)
+	if session == nil || session.RevokedAt == nil {
+		t.Fatalf("session not revoked: %+v", session)
+	}
+
+	// 同一工作階段最新的 refresh token 也隨之失效
+	if status, _ := sendWithToken(t, app, "/api/refresh", secondToken); status != fiber.StatusUnauthorized {
+		t.Fatalf("token of revoked session: status %d", status)
+	}
+}
+
+func TestRefreshTokenRejectsExpired(t *testing.T) {
+	original := configuration.RefreshTokenExpireDuration
+	configuration.RefreshTokenExpireDuration = -time.Minute
+	app, expiredToken := loginForRefresh(t, "refresh-expired@example.com")
+	configuration.RefreshTokenExpireDuration = original
+
+	if status, _ := sendWithToken(t, app, "/api/refresh", expiredToken); status != fiber.StatusUnauthorized {
+		t.Fatalf("expired token: status %d", status)
+	}
+	if status, _ := sendWithToken(t, app, "/api/refresh", "unknown"); status != fiber.StatusUnauthorized {
+		t.Fatalf("unknown token: status %d", status)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// 申請匯出個人資料，於背景產生壓縮檔
+func RequestDataExport(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+
+	// 同時只能有一個匯出在產生中
+	if database.HasPendingDataExport(accountID) {
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	dataExport := model.DataExport{
+		ID:        uuid.NewString(),
+		AccountID: accountID,
+		Status:    model.ExportPending,
+		CreatedAt: utils.GetCurrentTime(),
+	}
+	if !database.AddDataExport(&dataExport) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	helper.StartDataExport(dataExport)
+	recordSecurityEvent(context, accountID, model.SecurityEventDataExport, model.SecurityOutcomeSuccess, dataExport.ID)
+
+	return context.Status(fiber.StatusAccepted).JSON(dataExport)
+}
+
+// 取得個人資料匯出紀錄
+func GetDataExports(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+
+	return context.JSON(database.GetDataExports(accountID))
+}
+
+// 下載個人資料匯出檔
+func DownloadDataExport(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+
+	dataExport := database.GetDataExport(accountID, context.Params("id"))
+	if dataExport == nil || dataExport.Status != model.ExportCompleted || utils.GetCurrentTime().After(*dataExport.ExpiresAt) {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	return context.Download(dataExport.FilePath, "jimandy-export-"+dataExport.CompletedAt.Format("20060102")+".zip")
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"strings"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	loginStateCookie     = "login_state"    // 保存授權流程 state 與 nonce 的 cookie
+	loginStateCookiePath = "/api/login"     // cookie 只在登入相關 API 送出
+	loginStateDuration   = 10 * time.Minute // 授權流程逾時
+)
+
+// 導向外部身分提供者授權頁
+func RedirectIdentityProvider(context *fiber.Ctx) error {
+	providerName := context.Params("provider")
+	provider, ok := helper.IdentityProviders[providerName]
+	if !ok {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	state := generateRandomString(16)
+	nonce := generateRandomString(16)
+
+	authCodeURL := provider.AuthCodeURL(state, nonce)
+	if authCodeURL == "" {
+		return context.SendStatus(fiber.StatusBadGateway)
+	}
+
+	// state 與 nonce 簽章後存入發起登入的瀏覽器，回呼時必須由同一瀏覽器送回
+	setLoginStateCookie(context, setLoginState(providerName, state, nonce), utils.GetCurrentTime().Add(loginStateDuration))
+
+	return context.Redirect(authCodeURL, fiber.StatusFound)
+}
+
+// 外部身分提供者授權回呼
+func IdentityProviderCallback(context *fiber.Ctx) error {
+	providerName := context.Params("provider")
+	provider, ok := helper.IdentityProviders[providerName]
+	if !ok {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	var callback data.IdentityCallback
+	_ = context.BodyParser(&callback)
+
+	if callback.Code == "" || callback.State == "" {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// 檢查 state 由本服務發給此瀏覽器且屬於同一提供者，cookie 只能使用一次
+	nonce, ok := parseLoginState(context.Cookies(loginStateCookie), providerName, callback.State)
+	setLoginStateCookie(context, "", time.Unix(0, 0))
+	if !ok {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	identity, err := provider.Exchange(callback.Code, nonce)
+	if err != nil {
+		recordSecurityEvent(context, 0, model.SecurityEventLogin, model.SecurityOutcomeFailure, providerName)
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	account := resolveIdentityAccount(identity)
+	if account.ID == 0 {
+		recordSecurityEvent(context, 0, model.SecurityEventLogin, model.SecurityOutcomeFailure, providerName+" "+identity.Subject)
+		return context.SendStatus(fiber.StatusForbidden)
+	}
+
+	return completeLogin(context, &account, providerName)
+}
+
+// 產生簽章的授權流程狀態
+func setLoginState(provider string, state string, nonce string) string {
+	now := utils.GetCurrentTime()
+	claims := jwt.MapClaims{
+		"provider": provider,
+		"state":    state,
+		"nonce":    nonce,
+		"typ":      "login_state",
+		"iss":      configuration.JWTIssuer,
+		"aud":      configuration.JWTAudience,
+		"iat":      now.Unix(),
+		"exp":      now.Add(loginStateDuration).Unix(),
+	}
+	signedToken, _ := helper.SignToken(claims)
+
+	return signedToken
+}
+
+// 驗證授權流程狀態，回傳 nonce
+func parseLoginState(signedState string, provider string, state string) (string, bool) {
+	claims, ok := parseSignedToken(signedState, "login_state")
+	if !ok {
+		return "", false
+	}
+
+	cookieProvider, _ := claims["provider"].(string)
+	cookieState, _ := claims["state"].(string)
+	nonce, _ := claims["nonce"].(string)
+	if cookieProvider != provider || subtle.ConstantTimeCompare([]byte(cookieState), []byte(state)) != 1 {
+		return "", false
+	}
+
+	return nonce, true
+}
+
+// 設定或清除授權流程 cookie
+func setLoginStateCookie(context *fiber.Ctx, value string, expires time.Time) {
+	context.Cookie(&fiber.Cookie{
+		Name:     loginStateCookie,
+		Value:    value,
+		Path:     loginStateCookiePath,
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   strings.HasPrefix(configuration.SiteURL, "https://"),
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// 依外部身分取得帳號，必要時連結或建立帳號
+func resolveIdentityAccount(identity data.ExternalIdentity) (account model.Account) {
+	// 已連結的身分
+	if linked := database.GetIdentity(identity.Provider, identity.Subject); linked.ID != 0 {
+		return database.GetAccountByID(uint64(linked.AccountID))
+	}
+
+	switch {
+	// Strava 無電子郵件，依已連結的運動員取得帳號
+	// 其他提供者的 Subject 與運動員 ID 無關，不可用於對應帳號
+	case identity.Type == "strava":
+		athleteID, err := strconv.ParseUint(identity.Subject, 10, 64)
+		if err != nil {
+			return
+		}
+		athlete := database.GetAthleteByID(athleteID)
+		if athlete.ID == 0 || athlete.AccountID == 0 {
+			return
+		}
+		account = database.GetAccountByID(uint64(athlete.AccountID))
+
+	// 只以已驗證的電子郵件連結既有帳號或建立帳號
+	case identity.Email != "" && identity.EmailVerified:
+		account = database.GetAccountByEmail(identity.Email)
+		if account.ID == 0 {
+			account = model.Account{Name: identity.Name, Email: identity.Email}
+			if !database.AddAccount(&account) {
+				return model.Account{}
+			}
+		}
+
+	default:
+		return
+	}
+
+	if account.ID == 0 {
+		return
+	}
+
+	_ = database.AddIdentity(&model.Identity{
+		AccountID: account.ID,
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: utils.GetCurrentTime(),
+	})
+
+	return
+}
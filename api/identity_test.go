@@ -0,0 +1,244 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// 測試用 OpenID Connect 發行者，以自己的金鑰簽發 ID Token
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims // 下一次交換授權碼時簽發的 ID Token 內容
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(writer http.ResponseWriter, request *http.Request) {
+		_ = json.NewEncoder(writer).Encode(map[string]string{
+			"issuer":                 issuer.server.URL,
+			"authorization_endpoint": issuer.server.URL + "/authorize",
+			"token_endpoint":         issuer.server.URL + "/token",
+			"jwks_uri":               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(writer http.ResponseWriter, request *http.Request) {
+		_ = json.NewEncoder(writer).Encode(helper.JSONWebKeySet{Keys: []helper.JSONWebKey{{
+			KeyType:   "RSA",
+			KeyID:     "test",
+			Use:       "sig",
+			Algorithm: "RS256",
+			N:         base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(writer http.ResponseWriter, request *http.Request) {
+		if request.PostFormValue("code") != "good-code" || request.PostFormValue("client_secret") != "secret" {
+			writer.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(writer).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, issuer.claims)
+		token.Header["kid"] = "test"
+		idToken, _ := token.SignedString(key)
+		_ = json.NewEncoder(writer).Encode(map[string]string{"access_token": "access", "id_token": idToken})
+	})
+
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+// 設定使用測試發行者的身分提供者，測試結束後還原
+func useTestIdentityProvider(t *testing.T, issuer *testIssuer) {
+	original := helper.IdentityProviders
+	helper.IdentityProviders = map[string]helper.IdentityProvider{
+		"test": helper.NewOIDCProvider(configuration.IdentityProvider{
+			Name:         "test",
+			Type:         "oidc",
+			Issuer:       issuer.server.URL,
+			ClientID:     "client",
+			ClientSecret: "secret",
+			RedirectURL:  configuration.SiteURL + "/login/test/callback",
+		}, issuer.server.Client()),
+	}
+	t.Cleanup(func() { helper.IdentityProviders = original })
+}
+
+// 開始登入流程，回傳授權網址的 state、nonce 與登入狀態 cookie
+func beginIdentityLogin(t *testing.T, app *fiber.App) (string, string, *http.Cookie) {
+	t.Helper()
+
+	response, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/api/login/test", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != fiber.StatusFound {
+		t.Fatalf("redirect: status %d", response.StatusCode)
+	}
+
+	location, err := url.Parse(response.Header.Get(fiber.HeaderLocation))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cookie := range response.Cookies() {
+		if cookie.Name == loginStateCookie {
+			if !cookie.HttpOnly {
+				t.Fatal("login state cookie is not HttpOnly")
+			}
+			return location.Query().Get("state"), location.Query().Get("nonce"), cookie
+		}
+	}
+	t.Fatal("login state cookie not set")
+	return "", "", nil
+}
+
+// 送出授權回呼
+func sendIdentityCallback(t *testing.T, app *fiber.App, code string, state string, cookie *http.Cookie) (int, map[string]interface{}) {
+	t.Helper()
+
+	body, _ := json.Marshal(fiber.Map{"Code": code, "State": state})
+	request := httptest.NewRequest(fiber.MethodPost, "/api/login/test/callback", bytes.NewReader(body))
+	request.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if cookie != nil {
+		request.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	response, err := app.Test(request, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	result := map[string]interface{}{}
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	return response.StatusCode, result
+}
+
+func newIdentityApp() *fiber.App {
+	app := fiber.New()
+	app.Get("/api/login/:provider", RedirectIdentityProvider)
+	app.Post("/api/login/:provider/callback", IdentityProviderCallback)
+	return app
+}
+
+func TestIdentityProviderLogin(t *testing.T) {
+	issuer := newTestIssuer(t)
+	useTestIdentityProvider(t, issuer)
+	app := newIdentityApp()
+
+	state, nonce, cookie := beginIdentityLogin(t, app)
+	issuer.claims = jwt.MapClaims{
+		"iss":            issuer.server.URL,
+		"aud":            "client",
+		"sub":            "oidc-user",
+		"email":          "oidc@example.com",
+		"email_verified": true,
+		"name":           "OIDC",
+		"nonce":          nonce,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	}
+
+	status, tokens := sendIdentityCallback(t, app, "good-code", state, cookie)
+	if status != fiber.StatusOK || tokens["accessToken"] == nil {
+		t.Fatalf("callback: status %d, body %v", status, tokens)
+	}
+
+	account := database.GetAccountByEmail("oidc@example.com")
+	if account.ID == 0 {
+		t.Fatal("account not created")
+	}
+	if identity := database.GetIdentity("test", "oidc-user"); identity.AccountID != account.ID {
+		t.Fatalf("identity not linked: %+v", identity)
+	}
+}
+
+func TestIdentityProviderCallbackRejects(t *testing.T) {
+	issuer := newTestIssuer(t)
+	useTestIdentityProvider(t, issuer)
+	app := newIdentityApp()
+
+	validClaims := func(nonce string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            issuer.server.URL,
+			"aud":            "client",
+			"sub":            "rejected-user",
+			"email":          "rejected@example.com",
+			"email_verified": true,
+			"nonce":          nonce,
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		}
+	}
+
+	// 已連結運動員的帳號，其他提供者的 Subject 即使與運動員 ID 相同也不可登入
+	athleteOwner := model.Account{Name: "Athlete Owner", Email: "athlete-owner@example.com"}
+	if !database.AddAccount(&athleteOwner) || !database.AddAthlete(&model.Athlete{ID: 424242, AccountID: athleteOwner.ID}) {
+		t.Fatal("add athlete owner")
+	}
+
+	tests := []struct {
+		name   string
+		modify func(state *string, cookie **http.Cookie, claims jwt.MapClaims)
+		status int
+	}{
+		{"without cookie", func(state *string, cookie **http.Cookie, claims jwt.MapClaims) { *cookie = nil }, fiber.StatusUnauthorized},
+		{"other state", func(state *string, cookie **http.Cookie, claims jwt.MapClaims) { *state = "other" }, fiber.StatusUnauthorized},
+		{"other nonce", func(state *string, cookie **http.Cookie, claims jwt.MapClaims) { claims["nonce"] = "other" }, fiber.StatusUnauthorized},
+		{"other audience", func(state *string, cookie **http.Cookie, claims jwt.MapClaims) { claims["aud"] = "other" }, fiber.StatusUnauthorized},
+		{"expired", func(state *string, cookie **http.Cookie, claims jwt.MapClaims) {
+			claims["exp"] = time.Now().Add(-time.Minute).Unix()
+		}, fiber.StatusUnauthorized},
+		{"unverified email", func(state *string, cookie **http.Cookie, claims jwt.MapClaims) { claims["email_verified"] = false }, fiber.StatusForbidden},
+		{"without email", func(state *string, cookie **http.Cookie, claims jwt.MapClaims) {
+			claims["sub"] = "424242"
+			delete(claims, "email")
+			delete(claims, "email_verified")
+		}, fiber.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state, nonce, cookie := beginIdentityLogin(t, app)
+			issuer.claims = validClaims(nonce)
+			test.modify(&state, &cookie, issuer.claims)
+
+			if status, _ := sendIdentityCallback(t, app, "good-code", state, cookie); status != test.status {
+				t.Fatalf("status %d, want %d", status, test.status)
+			}
+		})
+	}
+
+	if database.GetAccountByEmail("rejected@example.com").ID != 0 {
+		t.Fatal("account created by a rejected callback")
+	}
+	if database.GetIdentity("test", "424242").ID != 0 {
+		t.Fatal("identity linked to the athlete owner")
+	}
+}
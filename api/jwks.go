@@ -0,0 +1,14 @@
+package api
+
+import (
+	"Jimandy-Website-Backend/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 取得驗證權杖用的公開金鑰
+func GetJWKS(context *fiber.Ctx) error {
+	context.Set(fiber.HeaderCacheControl, "public, max-age=300")
+
+	return context.JSON(helper.PublicJSONWebKeySet())
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 寄送免密碼登入連結
+func RequestMagicLink(context *fiber.Ctx) error {
+	var myLoginData data.Login
+	_ = context.BodyParser(&myLoginData)
+
+	// 若 沒Email 則 回錯誤
+	if myLoginData.Email == "" {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// 若 超過每帳號寄送次數 則 回請求過多
+	if !AllowRequest(context, "acct:"+strings.ToLower(myLoginData.Email), configuration.RateLimitPerAccount) {
+		return context.SendStatus(fiber.StatusTooManyRequests)
+	}
+
+	now := utils.GetCurrentTime()
+	token := generateRandomString(32)
+
+	magicLink := model.MagicLink{
+		Email:     myLoginData.Email,
+		Name:      myLoginData.Name,
+		TokenHash: database.HashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(configuration.MagicLinkExpireDuration),
+	}
+	if !database.AddMagicLink(&magicLink) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	link := fmt.Sprintf("%s/login/magic?token=%s", configuration.SiteURL, url.QueryEscape(token))
+	body := fmt.Sprintf("請點擊以下連結登入，連結將於 %d 分鐘後失效：\n\n%s", int(configuration.MagicLinkExpireDuration.Minutes()), link)
+
+	if err := helper.Mailer.Send(myLoginData.Email, "Jimandy 登入連結", body); err != nil {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	// 不論帳號是否存在皆回傳成功，避免洩漏帳號資訊
+	return context.SendStatus(fiber.StatusOK)
+}
+
+// 驗證免密碼登入連結並取得權杖
+func VerifyMagicLink(context *fiber.Ctx) error {
+	var myMagicLink data.MagicLink
+	_ = context.BodyParser(&myMagicLink)
+
+	if myMagicLink.Token == "" {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	magicLink := database.UseMagicLink(database.HashToken(myMagicLink.Token), utils.GetCurrentTime())
+	if magicLink == nil {
+		recordSecurityEvent(context, 0, model.SecurityEventLogin, model.SecurityOutcomeFailure, "magic_link")
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	// 若 帳號不存在 則 以已驗證的電子郵件建立帳號
+	account := database.GetAccountByEmail(magicLink.Email)
+	if account.ID == 0 {
+		account = model.Account{
+			Name:   magicLink.Name,
+			Email:  magicLink.Email,
+			Status: model.Enabled,
+		}
+		if !database.AddAccount(&account) {
+			return context.SendStatus(fiber.StatusBadRequest)
+		}
+	}
+
+	// 登入連結已驗證電子郵件，尚未啟用的帳號直接啟用
+	if account.Status == model.UnActive && database.ActivateAccount(account.ID, account.Email) {
+		account.Status = model.Enabled
+	}
+
+	return completeLogin(context, &account, "magic_link")
+}
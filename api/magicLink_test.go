@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"Jimandy-Website-Backend/database"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 寄出的登入連結
+var magicLinkPattern = regexp.MustCompile(`/login/magic\?token=(\S+)`)
+
+func TestMagicLinkLogin(t *testing.T) {
+	mailer := useFakeMailer(t)
+
+	app := fiber.New()
+	app.Post("/api/login/magiclink", RequestMagicLink)
+	app.Post("/api/login/magiclink/verify", VerifyMagicLink)
+
+	status, _ := sendJSON(t, app, fiber.MethodPost, "/api/login/magiclink", fiber.Map{"Email": "magic@example.com", "Name": "Magic"})
+	if status != fiber.StatusOK {
+		t.Fatalf("request magic link: status %d", status)
+	}
+
+	if len(mailer.messages) != 1 || mailer.messages[0].To != "magic@example.com" {
+		t.Fatalf("expected one mail to magic@example.com, got %+v", mailer.messages)
+	}
+	match := magicLinkPattern.FindStringSubmatch(mailer.messages[0].Body)
+	if match == nil {
+		t.Fatalf("mail body has no login link: %q", mailer.messages[0].Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次使用連結建立帳號並登入
+	status, tokens := sendJSON(t, app, fiber.MethodPost, "/api/login/magiclink/verify", fiber.Map{"Token": token})
+	if status != fiber.StatusOK || tokens["accessToken"] == nil || tokens["refreshToken"] == nil {
+		t.Fatalf("verify magic link: status %d, body %v", status, tokens)
+	}
+
+	account := database.GetAccountByEmail("magic@example.com")
+	if account.ID == 0 || account.Name != "Magic" {
+		t.Fatalf("account not created: %+v", account)
+	}
+
+	// 連結只能使用一次
+	if status, _ := sendJSON(t, app, fiber.MethodPost, "/api/login/magiclink/verify", fiber.Map{"Token": token}); status != fiber.StatusUnauthorized {
+		t.Fatalf("reused magic link: status %d", status)
+	}
+}
+
+func TestMagicLinkRejectsUnknownToken(t *testing.T) {
+	app := fiber.New()
+	app.Post("/api/login/magiclink/verify", VerifyMagicLink)
+
+	if status, _ := sendJSON(t, app, fiber.MethodPost, "/api/login/magiclink/verify", fiber.Map{"Token": "unknown"}); status != fiber.StatusUnauthorized {
+		t.Fatalf("unknown token: status %d", status)
+	}
+	if status, _ := sendJSON(t, app, fiber.MethodPost, "/api/login/magiclink/verify", fiber.Map{}); status != fiber.StatusBadRequest {
+		t.Fatalf("missing token: status %d", status)
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 以記憶體資料庫與測試用金鑰執行 api 測試
+func TestMain(m *testing.M) {
+	os.Setenv("KEY", "test-signing-key-0123456789abcdef")
+	os.Setenv("SITEURL", "http://localhost:61018")
+	configuration.ReadConfiguration()
+	configuration.EncryptionKeys = []configuration.EncryptionKey{
+		{KID: "test", Key: "dGVzdC1lbmNyeXB0aW9uLWtleS0wMTIzNDU2Nzg5YWI=", Active: true},
+	}
+
+	if err := helper.SetupSigningKeys(); err != nil {
+		panic(err)
+	}
+	if err := database.SetupTokenHashKey(); err != nil {
+		panic(err)
+	}
+	if err := database.SetupEncryptionKeys(); err != nil {
+		panic(err)
+	}
+	if err := helper.SetupWebAuthn(); err != nil {
+		panic(err)
+	}
+
+	connection, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		panic(err)
+	}
+	sqlDB, _ := connection.DB()
+	sqlDB.SetMaxOpenConns(1) // 記憶體資料庫只存在於同一連線
+	database.Use(connection)
+
+	os.Exit(m.Run())
+}
+
+// 記錄寄出郵件的測試用寄信方式
+type fakeMailSender struct {
+	messages []fakeMail
+}
+
+type fakeMail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+func (sender *fakeMailSender) Send(to string, subject string, body string) error {
+	sender.messages = append(sender.messages, fakeMail{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+// 替換寄信方式，測試結束後還原
+func useFakeMailer(t *testing.T) *fakeMailSender {
+	sender := &fakeMailSender{}
+	original := helper.Mailer
+	helper.Mailer = sender
+	t.Cleanup(func() { helper.Mailer = original })
+	return sender
+}
+
+// 送出 JSON 請求並回傳狀態碼與解析後的回應
+func sendJSON(t *testing.T, app *fiber.App, method string, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, _ := json.Marshal(body)
+		reader = bytes.NewReader(encoded)
+	}
+
+	request := httptest.NewRequest(method, path, reader)
+	request.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+	response, err := app.Test(request, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	result := map[string]interface{}{}
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	return response.StatusCode, result
+}
@@ -0,0 +1,33 @@
+package api
+
+import "github.com/gofiber/fiber/v2"
+
+const (
+	defaultPageSize = 50  // 預設每頁筆數
+	maxPageSize     = 200 // 每頁最多筆數
+)
+
+// 取得分頁參數 page(從 1 開始) 與 pageSize
+func getPagination(context *fiber.Ctx) (page int, pageSize int) {
+	page = context.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize = context.QueryInt("pageSize", defaultPageSize)
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = defaultPageSize
+	}
+
+	return
+}
+
+// 分頁回應
+func paginated(items interface{}, total int64, page int, pageSize int) fiber.Map {
+	return fiber.Map{
+		"items":    items,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	}
+}
@@ -0,0 +1,382 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofiber/fiber/v2"
+	"github.com/patrickmn/go-cache"
+)
+
+// 通行金鑰驗證流程，每個流程只能完成一次
+var passkeyCeremonyCache = cache.New(helper.WebAuthnTimeout, 2*helper.WebAuthnTimeout)
+
+// 通行金鑰驗證流程狀態
+type passkeyCeremony struct {
+	AccountID uint // 註冊流程的帳號，登入流程為 0
+	Session   webauthn.SessionData
+}
+
+// 通行金鑰使用者
+type passkeyUser struct {
+	account     *model.Account
+	credentials []model.WebAuthnCredential
+}
+
+// 帳號的使用者識別碼(不含個人資料)
+func passkeyUserHandle(accountID uint) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(accountID))
+	return handle
+}
+
+func (user *passkeyUser) WebAuthnID() []byte {
+	return passkeyUserHandle(user.account.ID)
+}
+
+func (user *passkeyUser) WebAuthnName() string {
+	return user.account.Email
+}
+
+func (user *passkeyUser) WebAuthnDisplayName() string {
+	if user.account.Name != "" {
+		return user.account.Name
+	}
+	return user.account.Email
+}
+
+func (user *passkeyUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (user *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(user.credentials))
+	for _, credential := range user.credentials {
+		transports := []protocol.AuthenticatorTransport{}
+		for _, transport := range strings.Split(credential.Transports, ",") {
+			if transport != "" {
+				transports = append(transports, protocol.AuthenticatorTransport(transport))
+			}
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              credential.CredentialID,
+			PublicKey:       credential.PublicKey,
+			AttestationType: credential.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				UserPresent:    true,
+				UserVerified:   credential.UserVerified,
+				BackupEligible: credential.BackupEligible,
+				BackupState:    credential.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    credential.AAGUID,
+				SignCount: credential.SignCount,
+			},
+		})
+	}
+	return credentials
+}
+
+// 建立帳號的通行金鑰使用者
+func newPasskeyUser(account *model.Account) *passkeyUser {
+	return &passkeyUser{account: account, credentials: database.GetWebAuthnCredentials(account.ID)}
+}
+
+// 暫存驗證流程並回傳流程 ID
+func savePasskeyCeremony(accountID uint, session *webauthn.SessionData) string {
+	ceremonyID := generateRandomString(16)
+	passkeyCeremonyCache.SetDefault(ceremonyID, passkeyCeremony{AccountID: accountID, Session: *session})
+	return ceremonyID
+}
+
+// 取出驗證流程(取出後即失效)
+func takePasskeyCeremony(ceremonyID string, accountID uint) (webauthn.SessionData, bool) {
+	cachedCeremony, found := passkeyCeremonyCache.Get(ceremonyID)
+	passkeyCeremonyCache.Delete(ceremonyID)
+	if !found || cachedCeremony.(passkeyCeremony).AccountID != accountID {
+		return webauthn.SessionData{}, false
+	}
+	return cachedCeremony.(passkeyCeremony).Session, true
+}
+
+// 開始註冊通行金鑰
+func BeginPasskeyRegistration(context *fiber.Ctx) error {
+	account := database.GetAccountByID(uint64(context.Locals("id").(float64)))
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	// 已註冊的通行金鑰不可重複註冊
+	user := newPasskeyUser(&account)
+	exclusions := []protocol.CredentialDescriptor{}
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := helper.WebAuthn.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return context.JSON(fiber.Map{
+		"ceremonyId": savePasskeyCeremony(account.ID, session),
+		"options":    creation,
+	})
+}
+
+// 完成註冊通行金鑰
+func FinishPasskeyRegistration(context *fiber.Ctx) error {
+	var myPasskey data.Passkey
+	_ = context.BodyParser(&myPasskey)
+
+	if myPasskey.CeremonyID == "" || len(myPasskey.Credential) == 0 || len(myPasskey.Name) > 64 {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	account := database.GetAccountByID(uint64(context.Locals("id").(float64)))
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	session, ok := takePasskeyCeremony(myPasskey.CeremonyID, account.ID)
+	if !ok {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	parsedResponse, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(myPasskey.Credential))
+	if err != nil {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	credential, err := helper.WebAuthn.CreateCredential(newPasskeyUser(&account), session, parsedResponse)
+	if err != nil {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// 未指定名稱時以裝置名稱顯示
+	name := myPasskey.Name
+	if name == "" {
+		_, _, name = utils.ParseUserAgent(context.Get("User-Agent"))
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	myCredential := model.WebAuthnCredential{
+		AccountID:       account.ID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      strings.Join(transports, ","),
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		UserVerified:    credential.Flags.UserVerified,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		CreatedAt:       utils.GetCurrentTime(),
+	}
+	if !database.AddWebAuthnCredential(&myCredential) {
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	recordSecurityEvent(context, account.ID, model.SecurityEventPasskeyAdded, model.SecurityOutcomeSuccess, myCredential.Name)
+
+	return context.JSON(fiber.Map{"id": myCredential.ID, "name": myCredential.Name})
+}
+
+// 取得當前使用者的通行金鑰
+func GetPasskeys(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+
+	passkeys := []fiber.Map{}
+	for _, credential := range database.GetWebAuthnCredentials(accountID) {
+		passkeys = append(passkeys, fiber.Map{
+			"id":         credential.ID,
+			"name":       credential.Name,
+			"backedUp":   credential.BackupState,
+			"createdAt":  credential.CreatedAt,
+			"lastUsedAt": credential.LastUsedAt,
+		})
+	}
+
+	return context.JSON(passkeys)
+}
+
+// 修改通行金鑰顯示名稱
+func RenamePasskey(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+	id, err := strconv.ParseUint(context.Params("id"), 10, 64)
+	if err != nil {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	var myPasskey data.Passkey
+	_ = context.BodyParser(&myPasskey)
+
+	if myPasskey.Name == "" || len(myPasskey.Name) > 64 {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if !database.RenameWebAuthnCredential(accountID, uint(id), myPasskey.Name) {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	return context.SendStatus(fiber.StatusOK)
+}
+
+// 移除通行金鑰
+func DeletePasskey(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+	id, err := strconv.ParseUint(context.Params("id"), 10, 64)
+	if err != nil {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if !database.DeleteWebAuthnCredential(accountID, uint(id)) {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	recordSecurityEvent(context, accountID, model.SecurityEventPasskeyRemoved, model.SecurityOutcomeSuccess, context.Params("id"))
+
+	return context.SendStatus(fiber.StatusOK)
+}
+
+// 開始以通行金鑰重新驗證身分(刪除帳號等敏感操作)
+func BeginPasskeyReauthentication(context *fiber.Ctx) error {
+	account := database.GetAccountByID(uint64(context.Locals("id").(float64)))
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	user := newPasskeyUser(&account)
+	if len(user.credentials) == 0 {
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	assertion, session, err := helper.WebAuthn.BeginLogin(user)
+	if err != nil {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return context.JSON(fiber.Map{
+		"ceremonyId": savePasskeyCeremony(account.ID, session),
+		"options":    assertion,
+	})
+}
+
+// 驗證重新驗證身分的通行金鑰回應
+func verifyPasskeyReauthentication(context *fiber.Ctx, account *model.Account, reauthentication *data.Reauthentication) bool {
+	session, ok := takePasskeyCeremony(reauthentication.CeremonyID, account.ID)
+	if !ok {
+		return false
+	}
+
+	parsedResponse, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(reauthentication.Credential))
+	if err != nil {
+		return false
+	}
+
+	credential, err := helper.WebAuthn.ValidateLogin(newPasskeyUser(account), session, parsedResponse)
+	if err != nil {
+		return false
+	}
+
+	storedCredential := database.GetWebAuthnCredentialByCredentialID(credential.ID)
+	if storedCredential == nil || storedCredential.AccountID != account.ID {
+		return false
+	}
+
+	// 若 簽章計數未遞增 則 通行金鑰可能遭複製，拒絕驗證
+	if credential.Authenticator.CloneWarning {
+		recordSecurityEvent(context, account.ID, model.SecurityEventPasskeyCloned, model.SecurityOutcomeFailure, storedCredential.Name)
+		return false
+	}
+
+	database.UpdateWebAuthnCredentialUsage(storedCredential.ID, credential.Authenticator.SignCount, credential.Flags.BackupState, utils.GetCurrentTime())
+
+	return true
+}
+
+// 開始以通行金鑰登入(不需輸入帳號)
+func BeginPasskeyLogin(context *fiber.Ctx) error {
+	assertion, session, err := helper.WebAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return context.JSON(fiber.Map{
+		"ceremonyId": savePasskeyCeremony(0, session),
+		"options":    assertion,
+	})
+}
+
+// 完成以通行金鑰登入
+func FinishPasskeyLogin(context *fiber.Ctx) error {
+	var myPasskey data.Passkey
+	_ = context.BodyParser(&myPasskey)
+
+	if myPasskey.CeremonyID == "" || len(myPasskey.Credential) == 0 {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	session, ok := takePasskeyCeremony(myPasskey.CeremonyID, 0)
+	if !ok {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	parsedResponse, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(myPasskey.Credential))
+	if err != nil {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// 依憑證ID找出通行金鑰，並確認與使用者識別碼屬於同一帳號
+	var account model.Account
+	var storedCredential *model.WebAuthnCredential
+	findUser := func(rawID []byte, userHandle []byte) (webauthn.User, error) {
+		storedCredential = database.GetWebAuthnCredentialByCredentialID(rawID)
+		if storedCredential == nil || !bytes.Equal(passkeyUserHandle(storedCredential.AccountID), userHandle) {
+			return nil, errors.New("credential not found")
+		}
+
+		account = database.GetAccountByID(uint64(storedCredential.AccountID))
+		if account.ID == 0 || !isAccountEnabled(&account) {
+			return nil, errors.New("account not found")
+		}
+
+		return newPasskeyUser(&account), nil
+	}
+
+	credential, err := helper.WebAuthn.ValidateDiscoverableLogin(findUser, session, parsedResponse)
+	if err != nil {
+		recordSecurityEvent(context, account.ID, model.SecurityEventLogin, model.SecurityOutcomeFailure, "passkey")
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	// 若 簽章計數未遞增 則 通行金鑰可能遭複製，拒絕登入
+	if credential.Authenticator.CloneWarning {
+		recordSecurityEvent(context, account.ID, model.SecurityEventPasskeyCloned, model.SecurityOutcomeFailure, storedCredential.Name)
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	database.UpdateWebAuthnCredentialUsage(storedCredential.ID, credential.Authenticator.SignCount, credential.Flags.BackupState, utils.GetCurrentTime())
+	recordSecurityEvent(context, account.ID, model.SecurityEventLogin, model.SecurityOutcomeSuccess, "passkey")
+
+	// 通行金鑰已驗證使用者(持有裝置並解鎖)，不需再經兩步驟驗證
+	return context.JSON(GenerateTokens(&account, context))
+}
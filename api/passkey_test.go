@@ -0,0 +1,237 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/gofiber/fiber/v2"
+)
+
+// 驗證器旗標
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// 測試用軟體驗證器，保存一把 ES256 通行金鑰
+type softwareAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftwareAuthenticator(t *testing.T) *softwareAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	_, _ = rand.Read(credentialID)
+
+	return &softwareAuthenticator{key: key, credentialID: credentialID}
+}
+
+// 產生驗證器資料
+func (authenticator *softwareAuthenticator) authenticatorData(flags byte, attestedData []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(configuration.WebAuthnRPID))
+
+	authData := append([]byte{}, rpIDHash[:]...)
+	authData = append(authData, flags)
+	authData = binary.BigEndian.AppendUint32(authData, authenticator.signCount)
+	return append(authData, attestedData...)
+}
+
+// 產生瀏覽器的用戶端資料
+func clientDataJSON(ceremonyType string, challenge string) []byte {
+	clientData, _ := json.Marshal(map[string]string{
+		"type":      ceremonyType,
+		"challenge": challenge,
+		"origin":    configuration.WebAuthnRPOrigins[0],
+	})
+	return clientData
+}
+
+// 依註冊選項建立通行金鑰，回傳瀏覽器的 PublicKeyCredential
+func (authenticator *softwareAuthenticator) create(t *testing.T, options map[string]interface{}) json.RawMessage {
+	t.Helper()
+
+	publicKey := options["publicKey"].(map[string]interface{})
+	user := publicKey["user"].(map[string]interface{})
+	userHandle, err := base64.RawURLEncoding.DecodeString(user["id"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.userHandle = userHandle
+
+	coseKey, _ := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: authenticator.key.X.FillBytes(make([]byte, 32)),
+		YCoord: authenticator.key.Y.FillBytes(make([]byte, 32)),
+	})
+
+	attestedData := make([]byte, 16) // AAGUID
+	attestedData = binary.BigEndian.AppendUint16(attestedData, uint16(len(authenticator.credentialID)))
+	attestedData = append(attestedData, authenticator.credentialID...)
+	attestedData = append(attestedData, coseKey...)
+
+	attestationObject, _ := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authenticator.authenticatorData(flagUserPresent|flagUserVerified|flagAttestedData, attestedData),
+	})
+
+	credential, _ := json.Marshal(map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(authenticator.credentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(authenticator.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientDataJSON("webauthn.create", publicKey["challenge"].(string))),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+		},
+	})
+	return credential
+}
+
+// 依登入選項簽署挑戰，回傳瀏覽器的 PublicKeyCredential
+func (authenticator *softwareAuthenticator) get(t *testing.T, options map[string]interface{}) json.RawMessage {
+	t.Helper()
+
+	publicKey := options["publicKey"].(map[string]interface{})
+	clientData := clientDataJSON("webauthn.get", publicKey["challenge"].(string))
+	authData := authenticator.authenticatorData(flagUserPresent|flagUserVerified, nil)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, authenticator.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, _ := json.Marshal(map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(authenticator.credentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(authenticator.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			"userHandle":        base64.RawURLEncoding.EncodeToString(authenticator.userHandle),
+		},
+	})
+	return credential
+}
+
+// 以登入帳號呼叫的通行金鑰 API
+func newPasskeyApp(accountID uint) *fiber.App {
+	app := fiber.New()
+	app.Post("/api/login/passkey/begin", BeginPasskeyLogin)
+	app.Post("/api/login/passkey/finish", FinishPasskeyLogin)
+
+	app.Use(func(context *fiber.Ctx) error {
+		context.Locals("id", float64(accountID))
+		return context.Next()
+	})
+	app.Post("/api/me/passkeys/register/begin", BeginPasskeyRegistration)
+	app.Post("/api/me/passkeys/register/finish", FinishPasskeyRegistration)
+	return app
+}
+
+// 開始驗證流程，回傳流程 ID 與選項
+func beginPasskeyCeremony(t *testing.T, app *fiber.App, path string) (string, map[string]interface{}) {
+	t.Helper()
+
+	status, result := sendJSON(t, app, fiber.MethodPost, path, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("%s: status %d", path, status)
+	}
+	return result["ceremonyId"].(string), result["options"].(map[string]interface{})
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	account := model.Account{Name: "Passkey", Email: "passkey@example.com", Status: model.Enabled}
+	if !database.AddAccount(&account) {
+		t.Fatal("add account")
+	}
+
+	app := newPasskeyApp(account.ID)
+	authenticator := newSoftwareAuthenticator(t)
+
+	// 註冊
+	ceremonyID, options := beginPasskeyCeremony(t, app, "/api/me/passkeys/register/begin")
+	status, result := sendJSON(t, app, fiber.MethodPost, "/api/me/passkeys/register/finish", fiber.Map{
+		"CeremonyID": ceremonyID,
+		"Name":       "Test key",
+		"Credential": authenticator.create(t, options),
+	})
+	if status != fiber.StatusOK || result["name"] != "Test key" {
+		t.Fatalf("finish registration: status %d, body %v", status, result)
+	}
+
+	credentials := database.GetWebAuthnCredentials(account.ID)
+	if len(credentials) != 1 || string(credentials[0].CredentialID) != string(authenticator.credentialID) {
+		t.Fatalf("credential not stored: %+v", credentials)
+	}
+
+	// 同一流程不可使用兩次
+	if status, _ := sendJSON(t, app, fiber.MethodPost, "/api/me/passkeys/register/finish", fiber.Map{
+		"CeremonyID": ceremonyID,
+		"Credential": authenticator.create(t, options),
+	}); status != fiber.StatusUnauthorized {
+		t.Fatalf("reused registration ceremony: status %d", status)
+	}
+
+	// 登入
+	authenticator.signCount++
+	ceremonyID, options = beginPasskeyCeremony(t, app, "/api/login/passkey/begin")
+	status, tokens := sendJSON(t, app, fiber.MethodPost, "/api/login/passkey/finish", fiber.Map{
+		"CeremonyID": ceremonyID,
+		"Credential": authenticator.get(t, options),
+	})
+	if status != fiber.StatusOK || tokens["accessToken"] == nil {
+		t.Fatalf("finish login: status %d, body %v", status, tokens)
+	}
+	if credential := database.GetWebAuthnCredentials(account.ID)[0]; credential.SignCount != authenticator.signCount {
+		t.Fatalf("sign count not updated: %d", credential.SignCount)
+	}
+
+	// 簽章計數未遞增時視為遭複製的通行金鑰
+	ceremonyID, options = beginPasskeyCeremony(t, app, "/api/login/passkey/begin")
+	if status, _ := sendJSON(t, app, fiber.MethodPost, "/api/login/passkey/finish", fiber.Map{
+		"CeremonyID": ceremonyID,
+		"Credential": authenticator.get(t, options),
+	}); status != fiber.StatusUnauthorized {
+		t.Fatalf("cloned passkey: status %d", status)
+	}
+}
+
+func TestPasskeyLoginRejectsUnknownCredential(t *testing.T) {
+	app := newPasskeyApp(0)
+	authenticator := newSoftwareAuthenticator(t)
+	authenticator.userHandle = passkeyUserHandle(1)
+
+	ceremonyID, options := beginPasskeyCeremony(t, app, "/api/login/passkey/begin")
+	if status, _ := sendJSON(t, app, fiber.MethodPost, "/api/login/passkey/finish", fiber.Map{
+		"CeremonyID": ceremonyID,
+		"Credential": authenticator.get(t, options),
+	}); status != fiber.StatusUnauthorized {
+		t.Fatalf("unknown credential: status %d", status)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/patrickmn/go-cache"
+)
+
+// 帳號權限快取，避免每個請求都查詢資料庫
+var permissionCache = cache.New(time.Minute, 5*time.Minute)
+
+// 帳號角色與權限
+type accountPermissions struct {
+	Roles       []string
+	Permissions map[string]bool
+}
+
+// 取得帳號角色與權限
+func getAccountPermissions(accountID uint) accountPermissions {
+	cacheKey := fmt.Sprintf("permission-%d", accountID)
+	if cachedData, found := permissionCache.Get(cacheKey); found {
+		return cachedData.(accountPermissions)
+	}
+
+	// 被要求兩步驟驗證但尚未啟用的管理員不授予管理員角色
+	account := database.GetAccountByID(uint64(accountID))
+	withholdAdmin := requiresTwoFactorSetup(&account)
+
+	result := accountPermissions{Permissions: map[string]bool{}}
+	for _, role := range database.GetAccountRoles(accountID) {
+		if withholdAdmin && role.Name == model.RoleAdmin {
+			continue
+		}
+		result.Roles = append(result.Roles, role.Name)
+		for _, permission := range role.Permissions {
+			result.Permissions[permission.Name] = true
+		}
+	}
+
+	permissionCache.SetDefault(cacheKey, result)
+
+	return result
+}
+
+// 清除帳號權限快取(角色異動後呼叫)，並通知其他執行個體
+func ClearPermissionCache(accountID uint) {
+	database.PublishPermissionChange(accountID)
+}
+
+// 清除本機的帳號權限快取(收到權限異動通知時呼叫)
+func ClearLocalPermissionCache(accountID uint) {
+	permissionCache.Delete(fmt.Sprintf("permission-%d", accountID))
+}
+
+// 檢查帳號是否擁有權限
+func HasPermission(accountID uint, permission string) bool {
+	return getAccountPermissions(accountID).Permissions[permission]
+}
+
+// 取得當前使用者的角色與權限
+func GetMyPermissions(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+	myPermissions := getAccountPermissions(accountID)
+
+	permissions := []string{}
+	for permission := range myPermissions.Permissions {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+
+	return context.JSON(fiber.Map{
+		"roles":       myPermissions.Roles,
+		"permissions": permissions,
+	})
+}
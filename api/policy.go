@@ -0,0 +1,53 @@
+package api
+
+import (
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 運動員資源存取層級
+type athleteAccess int
+
+const (
+	athleteAccessNone   athleteAccess = iota // 無權存取
+	athleteAccessPublic                      // 只能存取公開活動
+	athleteAccessViewer                      // 被授權檢視所有活動
+	athleteAccessOwner                       // 運動員本人
+)
+
+// 依登入帳號判斷對運動員的存取層級
+func getAthleteAccess(context *fiber.Ctx, athleteID uint64) (model.Athlete, athleteAccess) {
+	accountID := uint(context.Locals("id").(float64)) // 登入帳號主鍵
+
+	// 依登入帳號取得自己的運動員
+	myAthlete := database.GetAthleteByAccountID(accountID)
+	if myAthlete.ID != 0 && myAthlete.ID == athleteID {
+		return myAthlete, athleteAccessOwner
+	}
+
+	athlete := database.GetAthleteByID(athleteID)
+	if athlete.ID == 0 {
+		return athlete, athleteAccessNone
+	}
+
+	if database.IsAthleteViewer(athlete.ID, accountID) {
+		return athlete, athleteAccessViewer
+	}
+
+	if athlete.IsPublic {
+		return athlete, athleteAccessPublic
+	}
+
+	return athlete, athleteAccessNone
+}
+
+// 檢查是否可存取活動，活動需屬於該運動員，公開存取只限公開活動
+func canAccessActivity(athlete model.Athlete, access athleteAccess, activity model.Activity) bool {
+	if access == athleteAccessNone || activity.ID == 0 || activity.AthleteID != athlete.ID {
+		return false
+	}
+
+	return access != athleteAccessPublic || activity.Visibility == model.VisibilityEveryone
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"testing"
+
+	"Jimandy-Website-Backend/model"
+)
+
+func TestCanAccessActivity(t *testing.T) {
+	athlete := model.Athlete{ID: 1}
+	public := model.Activity{ID: 10, AthleteID: 1, Visibility: model.VisibilityEveryone}
+	private := model.Activity{ID: 11, AthleteID: 1, Visibility: model.VisibilityOnlyMe}
+	unknown := model.Activity{ID: 12, AthleteID: 1}
+	others := model.Activity{ID: 13, AthleteID: 2, Visibility: model.VisibilityEveryone}
+
+	tests := []struct {
+		name     string
+		access   athleteAccess
+		activity model.Activity
+		allowed  bool
+	}{
+		{"owner public", athleteAccessOwner, public, true},
+		{"owner private", athleteAccessOwner, private, true},
+		{"owner without visibility", athleteAccessOwner, unknown, true},
+		{"viewer private", athleteAccessViewer, private, true},
+		{"public public", athleteAccessPublic, public, true},
+		{"public private", athleteAccessPublic, private, false},
+		{"public without visibility", athleteAccessPublic, unknown, false},
+		{"none public", athleteAccessNone, public, false},
+		{"owner other athlete", athleteAccessOwner, others, false},
+		{"owner missing activity", athleteAccessOwner, model.Activity{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if allowed := canAccessActivity(athlete, test.access, test.activity); allowed != test.allowed {
+				t.Fatalf("allowed %v, want %v", allowed, test.allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 設定 Retry-After 標頭(秒，無條件進位)
+func setRetryAfter(context *fiber.Ctx, wait time.Duration) {
+	context.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// 檢查並記錄一次呼叫，超過次數時設定 Retry-After 並回傳 false
+func AllowRequest(context *fiber.Ctx, key string, limit int) bool {
+	allowed, wait := helper.Limiter.Allow(key, limit, configuration.RateLimitWindow)
+	if !allowed {
+		setRetryAfter(context, wait)
+	}
+	return allowed
+}
+
+// 登入失敗紀錄的鍵值
+func loginFailureKey(email string) string {
+	return "login-failure:" + strings.ToLower(email)
+}
+
+// 開始一次登入嘗試並預先記錄為失敗，帳號因連續登入失敗而鎖定時設定 Retry-After 並回傳 false
+func beginLoginAttempt(context *fiber.Ctx, email string) bool {
+	return beginAttempt(context, loginFailureKey(email))
+}
+
+// 開始一次需驗證的嘗試並預先記錄為失敗，鎖定時設定 Retry-After 並回傳 false
+func beginAttempt(context *fiber.Ctx, key string) bool {
+	wait := helper.Limiter.BeginAttempt(key)
+	if wait > 0 {
+		setRetryAfter(context, wait)
+		return false
+	}
+	return true
+}
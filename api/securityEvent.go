@@ -0,0 +1,51 @@
+package api
+
+import (
+	"time"
+
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 取得當前使用者的安全事件
+func GetMySecurityEvents(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+	page, pageSize := getPagination(context)
+
+	filter := data.SecurityEventFilter{AccountID: accountID}
+	events, total := database.GetSecurityEvents(filter, (page-1)*pageSize, pageSize)
+
+	return context.JSON(paginated(events, total, page, pageSize))
+}
+
+// 依條件查詢所有安全事件(管理員)
+// 可用條件 accountId、actorId、type、outcome、ip、from、to(RFC 3339)
+func GetSecurityEvents(context *fiber.Ctx) error {
+	page, pageSize := getPagination(context)
+
+	filter := data.SecurityEventFilter{
+		AccountID: uint(context.QueryInt("accountId")),
+		ActorID:   uint(context.QueryInt("actorId")),
+		Type:      context.Query("type"),
+		Outcome:   context.Query("outcome"),
+		IP:        context.Query("ip"),
+	}
+
+	var err error
+	if from := context.Query("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return context.SendStatus(fiber.StatusBadRequest)
+		}
+	}
+	if to := context.Query("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return context.SendStatus(fiber.StatusBadRequest)
+		}
+	}
+
+	events, total := database.GetSecurityEvents(filter, (page-1)*pageSize, pageSize)
+
+	return context.JSON(paginated(events, total, page, pageSize))
+}
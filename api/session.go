@@ -0,0 +1,102 @@
+package api
+
+import (
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// 依請求建立新的工作階段(尚未儲存)
+func newSession(account *model.Account, context *fiber.Ctx) model.Session {
+	now := utils.GetCurrentTime()
+	userAgent := context.Get("User-Agent")
+	browser, os, device := utils.ParseUserAgent(userAgent)
+
+	return model.Session{
+		ID:          uuid.NewString(),
+		AccountID:   account.ID,
+		Name:        browser + " on " + os,
+		Browser:     browser,
+		OS:          os,
+		Device:      device,
+		UserAgent:   userAgent,
+		LastIP:      context.IP(),
+		FirstSeenAt: now,
+		LastSeenAt:  now,
+	}
+}
+
+// 取得登入帳號的工作階段，不屬於登入帳號時回傳 nil
+func getMySession(context *fiber.Ctx) *model.Session {
+	accountID := uint(context.Locals("id").(float64))
+
+	session := database.GetSessionByID(context.Params("sessionId"))
+	if session == nil || session.AccountID != accountID || session.RevokedAt != nil {
+		return nil
+	}
+	return session
+}
+
+// 取得用戶的所有裝置
+func GetUserDevices(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+	currentSessionID, _ := context.Locals("sid").(string)
+
+	deviceList := []fiber.Map{}
+	for _, session := range database.GetAccountSessions(accountID, utils.GetCurrentTime()) {
+		deviceList = append(deviceList, fiber.Map{
+			"sessionId":   session.ID,
+			"name":        session.Name,
+			"browser":     session.Browser,
+			"os":          session.OS,
+			"device":      session.Device,
+			"ip":          session.LastIP,
+			"firstSeenAt": session.FirstSeenAt,
+			"lastSeenAt":  session.LastSeenAt,
+			"current":     session.ID == currentSessionID,
+		})
+	}
+
+	return context.JSON(deviceList)
+}
+
+// 修改裝置顯示名稱
+func RenameDevice(context *fiber.Ctx) error {
+	session := getMySession(context)
+	if session == nil {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	var myDevice data.Device
+	_ = context.BodyParser(&myDevice)
+
+	if myDevice.Name == "" || len(myDevice.Name) > 64 {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if !database.RenameSession(session.ID, myDevice.Name) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return context.SendStatus(fiber.StatusOK)
+}
+
+// 登出特定裝置
+func LogoutDevice(context *fiber.Ctx) error {
+	session := getMySession(context)
+	if session == nil {
+		return context.SendStatus(fiber.StatusNotFound)
+	}
+
+	if !database.RevokeSession(session.ID, utils.GetCurrentTime()) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	recordSecurityEvent(context, session.AccountID, model.SecurityEventDeviceRevoked, model.SecurityOutcomeSuccess, "session "+session.ID)
+
+	return context.SendStatus(fiber.StatusOK)
+}
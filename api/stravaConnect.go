@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/patrickmn/go-cache"
+)
+
+// 連結 Strava 的 state 逾時
+const stravaStateDuration = 10 * time.Minute
+
+// 已使用的 state，state 只能使用一次
+var usedStravaStateCache = cache.New(stravaStateDuration, 2*stravaStateDuration)
+
+// 導向 Strava 授權頁以連結運動員
+// 以 fetch 呼叫時(Accept: application/json)回傳授權網址，由前端自行導向
+func ConnectStrava(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64)) // 登入帳號主鍵
+
+	if configuration.StravaClientID == 0 || configuration.StravaClientSecret == "" {
+		return context.SendStatus(fiber.StatusNotImplemented)
+	}
+
+	authorizeURL := helper.StravaAuthorizeURL(setStravaState(accountID))
+
+	if context.Accepts(fiber.MIMETextHTML, fiber.MIMEApplicationJSON) == fiber.MIMEApplicationJSON {
+		return context.JSON(fiber.Map{"url": authorizeURL})
+	}
+
+	return context.Redirect(authorizeURL, fiber.StatusFound)
+}
+
+// Strava 授權回呼，以授權碼交換權杖並將運動員連結至登入帳號
+func StravaCallback(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64)) // 登入帳號主鍵
+
+	var callback data.StravaCallback
+	_ = context.BodyParser(&callback)
+
+	if callback.Code == "" || callback.State == "" {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// state 必須由本服務簽發給同一帳號
+	if !verifyStravaState(callback.State, accountID) {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	// 使用者可在授權頁取消勾選範圍，缺少必要範圍時不連結
+	grantedScopes := strings.Split(callback.Scope, ",")
+	for _, scope := range configuration.StravaScopes {
+		if !slices.Contains(grantedScopes, scope) {
+			recordSecurityEvent(context, accountID, model.SecurityEventAthleteLinked, model.SecurityOutcomeFailure, "missing scope "+scope)
+			return context.Status(fiber.StatusForbidden).JSON(fiber.Map{"requiredScopes": configuration.StravaScopes})
+		}
+	}
+
+	tokens, err := helper.ExchangeStravaCode(callback.Code)
+	if err != nil {
+		recordSecurityEvent(context, accountID, model.SecurityEventAthleteLinked, model.SecurityOutcomeFailure, "code exchange")
+		return context.SendStatus(fiber.StatusBadGateway)
+	}
+
+	// 運動員已連結其他帳號，或帳號已連結其他運動員
+	existing := database.GetAthleteByID(tokens.Athlete.AthleteID)
+	linked := database.GetAthleteByAccountID(accountID)
+	if (existing.ID != 0 && existing.AccountID != accountID) || (linked.ID != 0 && linked.ID != tokens.Athlete.AthleteID) {
+		recordSecurityEvent(context, accountID, model.SecurityEventAthleteLinked, model.SecurityOutcomeFailure, fmt.Sprintf("athlete %d conflict", tokens.Athlete.AthleteID))
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	myAthlete := model.Athlete{
+		ID:             tokens.Athlete.AthleteID,
+		AccountID:      accountID,
+		ClientID:       configuration.StravaClientID,
+		AccessToken:    tokens.AccessToken,
+		RefreshToken:   tokens.RefreshToken,
+		TokenExpiresAt: helper.StravaTokenExpiry(tokens),
+		IsPublic:       existing.IsPublic,
+	}
+	if !database.AddAthlete(&myAthlete) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	recordSecurityEvent(context, accountID, model.SecurityEventAthleteLinked, model.SecurityOutcomeSuccess, fmt.Sprintf("athlete %d", myAthlete.ID))
+
+	return context.JSON(fiber.Map{"athleteId": myAthlete.ID})
+}
+
+// 產生連結 Strava 用的 state(綁定帳號)
+func setStravaState(accountID uint) string {
+	now := utils.GetCurrentTime()
+	claims := jwt.MapClaims{
+		"id":  accountID,
+		"typ": "strava_state",
+		"iss": configuration.JWTIssuer,
+		"aud": configuration.JWTAudience,
+		"iat": now.Unix(),
+		"exp": now.Add(stravaStateDuration).Unix(),
+		"jti": generateRandomString(16),
+	}
+	signedToken, _ := helper.SignToken(claims)
+
+	return signedToken
+}
+
+// 驗證 state 屬於該帳號且尚未使用
+func verifyStravaState(state string, accountID uint) bool {
+	claims, ok := parseSignedToken(state, "strava_state")
+	if !ok {
+		return false
+	}
+
+	id, _ := claims["id"].(float64)
+	jti, _ := claims["jti"].(string)
+	if uint(id) != accountID || jti == "" {
+		return false
+	}
+
+	return usedStravaStateCache.Add(jti, true, cache.DefaultExpiration) == nil
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	stravaEventMaxAttempts   = 5                // Strava 推播事件最多處理次數
+	stravaEventRetryInterval = time.Minute      // 重新處理失敗事件的間隔
+	stravaEventClaimTimeout  = 10 * time.Minute // 認領後未完成的事件逾時後可由其他工作重新處理
+	stravaEventBatchSize     = 10               // 每次定期重試最多認領的事件數
+)
+
+// 待處理的 Strava 推播事件，佇列已滿時留待定期重試
+var stravaEventQueue = make(chan model.StravaEvent, 256)
+
+// 驗證 Strava 推播訂閱
+func VerifyStravaWebhook(context *fiber.Ctx) error {
+	verifyToken := context.Query("hub.verify_token")
+
+	if context.Query("hub.mode") != "subscribe" || configuration.StravaWebhookVerifyToken == "" ||
+		subtle.ConstantTimeCompare([]byte(verifyToken), []byte(configuration.StravaWebhookVerifyToken)) != 1 {
+		return context.SendStatus(fiber.StatusForbidden)
+	}
+
+	return context.JSON(fiber.Map{"hub.challenge": context.Query("hub.challenge")})
+}
+
+// 接收 Strava 推播事件，Strava 要求兩秒內回應，事件於背景處理
+func ReceiveStravaWebhook(context *fiber.Ctx) error {
+	// 推播請求沒有簽章，依 IP 限流避免大量偽造的事件
+	if !AllowRequest(context, "strava-webhook-ip:"+context.IP(), configuration.StravaWebhookRateLimitPerIP) {
+		return context.SendStatus(fiber.StatusTooManyRequests)
+	}
+
+	var myEvent data.StravaEvent
+	if err := json.Unmarshal(context.Body(), &myEvent); err != nil || myEvent.OwnerID == 0 || myEvent.ObjectID == 0 {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// 若 不是來自設定的推播訂閱 則 拒絕
+	if configuration.StravaWebhookSubscriptionID == 0 || myEvent.SubscriptionID != configuration.StravaWebhookSubscriptionID {
+		return context.SendStatus(fiber.StatusForbidden)
+	}
+
+	updates, _ := json.Marshal(myEvent.Updates)
+
+	event := model.StravaEvent{
+		SubscriptionID: myEvent.SubscriptionID,
+		OwnerID:        myEvent.OwnerID,
+		ObjectType:     myEvent.ObjectType,
+		ObjectID:       myEvent.ObjectID,
+		AspectType:     myEvent.AspectType,
+		EventTime:      myEvent.EventTime,
+		Updates:        string(updates),
+		ReceivedAt:     time.Now().UTC(),
+	}
+
+	// 重複送達的事件直接回應成功
+	if !database.AddStravaEvent(&event) {
+		return context.SendStatus(fiber.StatusOK)
+	}
+
+	select {
+	case stravaEventQueue <- event:
+	default:
+	}
+
+	return context.SendStatus(fiber.StatusOK)
+}
+
+// 在背景依序處理 Strava 推播事件，並定期重新處理失敗或未處理的事件
+func StartStravaEventWorker() {
+	go func() {
+		ticker := time.NewTicker(stravaEventRetryInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case event := <-stravaEventQueue:
+				// 定期重試或其他執行個體已認領時略過
+				if database.ClaimStravaEvent(&event, stravaEventClaimTimeout) {
+					processStravaEvent(event)
+				}
+			case <-ticker.C:
+				processStravaEvents(database.ClaimPendingStravaEvents(stravaEventMaxAttempts, stravaEventClaimTimeout, stravaEventBatchSize))
+			}
+		}
+	}()
+}
+
+// 同一物件的同類事件只需向 Strava 取得一次最新狀態
+func stravaEventKey(event model.StravaEvent) string {
+	return fmt.Sprintf("%d:%d:%s", event.OwnerID, event.ObjectID, event.AspectType)
+}
+
+// 依序處理多筆事件，同一物件的同類事件合併處理，其餘事件沿用第一筆的處理結果
+func processStravaEvents(events []model.StravaEvent) {
+	results := map[string]error{}
+	for _, event := range events {
+		key := stravaEventKey(event)
+		if err, processed := results[key]; processed {
+			// 處理成功時已一併完成，失敗時記錄相同的錯誤
+			database.FinishStravaEvent(&event, err)
+			continue
+		}
+		results[key] = processStravaEvent(event)
+	}
+}
+
+func processStravaEvent(event model.StravaEvent) error {
+	err := helper.ProcessStravaEvent(event)
+	if err != nil {
+		log.Printf("Strava event %d failed: %v", event.ID, err)
+	} else {
+		activityCache.Delete(fmt.Sprintf("activity-%d", event.OwnerID))
+	}
+
+	database.FinishStravaEvent(&event, err)
+
+	return err
+}
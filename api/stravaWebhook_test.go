@@ -0,0 +1,204 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Strava 推播的事件內容
+const (
+	recordedActivityCreate    = `{"aspect_type":"create","event_time":1549560669,"object_id":1360128428,"object_type":"activity","owner_id":134815,"subscription_id":120475,"updates":{}}`
+	recordedDeauthorize       = `{"aspect_type":"update","event_time":1516126240,"object_id":134815,"object_type":"athlete","owner_id":134815,"subscription_id":120475,"updates":{"authorized":"false"}}`
+	recordedOtherSubscription = `{"aspect_type":"delete","event_time":1516126140,"object_id":1360128428,"object_type":"activity","owner_id":134815,"subscription_id":999,"updates":{}}`
+)
+
+// 送出推播事件並回傳狀態碼
+func postStravaEvent(t *testing.T, app *fiber.App, payload string) int {
+	t.Helper()
+
+	request := httptest.NewRequest(fiber.MethodPost, "/api/strava/webhook", bytes.NewReader([]byte(payload)))
+	request.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+	response, err := app.Test(request, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.StatusCode
+}
+
+func TestReceiveStravaWebhook(t *testing.T) {
+	original := configuration.StravaWebhookSubscriptionID
+	configuration.StravaWebhookSubscriptionID = 120475
+	t.Cleanup(func() { configuration.StravaWebhookSubscriptionID = original })
+
+	app := fiber.New()
+	app.Post("/api/strava/webhook", ReceiveStravaWebhook)
+
+	tests := []struct {
+		name    string
+		payload string
+		status  int
+		queued  bool
+	}{
+		{"activity create", recordedActivityCreate, fiber.StatusOK, true},
+		{"duplicate delivery", recordedActivityCreate, fiber.StatusOK, false},
+		{"deauthorize", recordedDeauthorize, fiber.StatusOK, true},
+		{"other subscription", recordedOtherSubscription, fiber.StatusForbidden, false},
+		{"malformed", `{"object_type":`, fiber.StatusBadRequest, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := postStravaEvent(t, app, test.payload); status != test.status {
+				t.Fatalf("status %d, want %d", status, test.status)
+			}
+
+			select {
+			case event := <-stravaEventQueue:
+				if !test.queued {
+					t.Fatalf("unexpected queued event %+v", event)
+				}
+				if event.ID == 0 || event.SubscriptionID != 120475 {
+					t.Fatalf("event not stored: %+v", event)
+				}
+			default:
+				if test.queued {
+					t.Fatal("event not queued")
+				}
+			}
+		})
+	}
+
+	// 每個事件只會被認領一次，佇列與定期重試不會重複處理
+	events := database.ClaimPendingStravaEvents(stravaEventMaxAttempts, stravaEventClaimTimeout, stravaEventBatchSize)
+	if len(events) != 2 || events[0].AspectType != "create" || events[1].ObjectType != "athlete" {
+		t.Fatalf("claimed %+v", events)
+	}
+	if len(database.ClaimPendingStravaEvents(stravaEventMaxAttempts, stravaEventClaimTimeout, stravaEventBatchSize)) != 0 {
+		t.Fatal("claimed events returned again")
+	}
+	queued := events[0]
+	if database.ClaimStravaEvent(&queued, stravaEventClaimTimeout) {
+		t.Fatal("claimed event claimed again from the queue")
+	}
+
+	// 處理失敗後可再次認領
+	if !database.FinishStravaEvent(&events[0], errors.New("strava unavailable")) {
+		t.Fatal("claimed event not finished")
+	}
+	if !database.ClaimStravaEvent(&events[0], stravaEventClaimTimeout) {
+		t.Fatal("failed event not claimable")
+	}
+}
+
+func TestReceiveStravaWebhookRateLimit(t *testing.T) {
+	originalLimiter, originalLimit := helper.Limiter, configuration.StravaWebhookRateLimitPerIP
+	helper.Limiter = &helper.RateLimiter{Store: helper.NewMemoryRateLimitStore()}
+	configuration.StravaWebhookRateLimitPerIP = 2
+	t.Cleanup(func() {
+		helper.Limiter, configuration.StravaWebhookRateLimitPerIP = originalLimiter, originalLimit
+	})
+
+	app := fiber.New()
+	app.Post("/api/strava/webhook", ReceiveStravaWebhook)
+
+	for i := 0; i < 2; i++ {
+		if status := postStravaEvent(t, app, `{"object_type":`); status != fiber.StatusBadRequest {
+			t.Fatalf("request %d: status %d", i+1, status)
+		}
+	}
+	if status := postStravaEvent(t, app, recordedActivityCreate); status != fiber.StatusTooManyRequests {
+		t.Fatalf("over limit: status %d", status)
+	}
+}
+
+// 新增尚未處理的推播事件
+func addStravaEvent(t *testing.T, ownerID uint64, objectID uint64, aspectType string, eventTime int64) model.StravaEvent {
+	t.Helper()
+
+	event := model.StravaEvent{
+		SubscriptionID: 120475,
+		OwnerID:        ownerID,
+		ObjectType:     model.StravaObjectActivity,
+		ObjectID:       objectID,
+		AspectType:     aspectType,
+		EventTime:      eventTime,
+		Updates:        "{}",
+		ReceivedAt:     time.Now().UTC(),
+	}
+	if !database.AddStravaEvent(&event) {
+		t.Fatal("add strava event")
+	}
+	return event
+}
+
+func TestFinishStravaEventRejectsStaleClaim(t *testing.T) {
+	event := addStravaEvent(t, 777001, 1, model.StravaAspectCreate, 1)
+
+	stale := event
+	if !database.ClaimStravaEvent(&stale, stravaEventClaimTimeout) {
+		t.Fatal("claim event")
+	}
+
+	// 認領逾時後由其他工作重新認領
+	time.Sleep(time.Millisecond)
+	current := event
+	if !database.ClaimStravaEvent(&current, 0) {
+		t.Fatal("reclaim timed out event")
+	}
+
+	if database.FinishStravaEvent(&stale, errors.New("strava unavailable")) {
+		t.Fatal("stale claim finished the event")
+	}
+	if !database.FinishStravaEvent(&current, nil) {
+		t.Fatal("current claim not finished")
+	}
+
+	events := database.ClaimPendingStravaEvents(stravaEventMaxAttempts, 0, stravaEventBatchSize)
+	for _, pending := range events {
+		if pending.ID == event.ID {
+			t.Fatalf("finished event claimed again: %+v", pending)
+		}
+	}
+}
+
+func TestProcessStravaEventsCoalesces(t *testing.T) {
+	// 未連結的運動員不需向 Strava 取得資料，處理一律成功
+	first := addStravaEvent(t, 777002, 2, model.StravaAspectUpdate, 1)
+	addStravaEvent(t, 777002, 2, model.StravaAspectUpdate, 2)
+	addStravaEvent(t, 777002, 2, model.StravaAspectUpdate, 3)
+	other := addStravaEvent(t, 777002, 2, model.StravaAspectDelete, 4)
+
+	events := database.ClaimPendingStravaEvents(stravaEventMaxAttempts, stravaEventClaimTimeout, stravaEventBatchSize)
+	if len(events) != 4 {
+		t.Fatalf("claimed %+v", events)
+	}
+
+	processStravaEvents(events)
+
+	var stored []model.StravaEvent
+	for _, event := range events {
+		stored = append(stored, database.GetStravaEventByID(event.ID))
+	}
+
+	for _, event := range stored {
+		if event.ProcessedAt == nil || event.ProcessingAt != nil {
+			t.Fatalf("event not completed: %+v", event)
+		}
+		// 同一物件的同類事件只處理第一筆
+		processed := event.ID == first.ID || event.ID == other.ID
+		if processed != (event.Attempts == 1) {
+			t.Fatalf("event %d processed %d times", event.ID, event.Attempts)
+		}
+	}
+}
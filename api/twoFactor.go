@@ -0,0 +1,279 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// 每次產生的備用碼數量
+const recoveryCodeCount = 10
+
+// 檢查帳號是否被要求啟用兩步驟驗證但尚未啟用
+func requiresTwoFactorSetup(account *model.Account) bool {
+	return configuration.RequireAdminTwoFactor && account.IsAdmin == 1 && !account.TOTPEnabled
+}
+
+// 完成第一步登入：啟用兩步驟驗證的帳號回傳驗證權杖，否則直接發放權杖
+// method 為第一步的登入方式，記錄於安全事件
+func completeLogin(context *fiber.Ctx, account *model.Account, method string) error {
+	// 若 帳號停用或尚未啟用 則 回禁止
+	if !isAccountEnabled(account) {
+		recordSecurityEvent(context, account.ID, model.SecurityEventLogin, model.SecurityOutcomeFailure, method+" account disabled")
+		return context.SendStatus(fiber.StatusForbidden)
+	}
+
+	if account.TOTPEnabled {
+		return context.JSON(fiber.Map{
+			"twoFactorRequired": true,
+			"challengeToken":    setChallengeToken(account, method),
+		})
+	}
+
+	recordSecurityEvent(context, account.ID, model.SecurityEventLogin, model.SecurityOutcomeSuccess, method)
+
+	tokens := GenerateTokens(account, context)
+	if requiresTwoFactorSetup(account) {
+		tokens["twoFactorSetupRequired"] = true // 啟用前不授予管理員權限
+	}
+
+	return context.JSON(tokens)
+}
+
+// 產生兩步驟驗證登入權杖(不可作為 access token 使用)
+func setChallengeToken(account *model.Account, method string) string {
+	now := utils.GetCurrentTime()
+	claims := jwt.MapClaims{
+		"id":  account.ID,
+		"amr": method,
+		"typ": "mfa",
+		"iss": configuration.JWTIssuer,
+		"aud": configuration.JWTAudience,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(configuration.TwoFactorChallengeDuration).Unix(),
+		"jti": generateRandomString(16),
+	}
+	signedToken, _ := helper.SignToken(claims)
+
+	return signedToken
+}
+
+// 解析兩步驟驗證登入權杖，回傳帳號主鍵與第一步的登入方式
+func parseChallengeToken(challengeToken string) (uint, string, bool) {
+	claims, ok := parseSignedToken(challengeToken, "mfa")
+	if !ok {
+		return 0, "", false
+	}
+
+	id, hasID := claims["id"].(float64)
+	method, _ := claims["amr"].(string)
+	return uint(id), method, hasID
+}
+
+// 兩步驟驗證失敗紀錄的鍵值
+func twoFactorFailureKey(accountID uint) string {
+	return fmt.Sprintf("2fa-failure:%d", accountID)
+}
+
+// 正規化備用碼(忽略大小寫與連字號)
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// 產生備用碼，回傳明碼與要儲存的雜湊
+func generateRecoveryCodes(accountID uint) ([]string, []model.RecoveryCode) {
+	now := utils.GetCurrentTime()
+	codes := make([]string, 0, recoveryCodeCount)
+	recoveryCodes := make([]model.RecoveryCode, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		code := generateRandomString(5)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		recoveryCodes = append(recoveryCodes, model.RecoveryCode{
+			AccountID: accountID,
+			CodeHash:  database.HashToken(code),
+			CreatedAt: now,
+		})
+	}
+
+	return codes, recoveryCodes
+}
+
+// 驗證 TOTP 驗證碼，同一驗證碼只能使用一次
+func verifyTOTP(account *model.Account, code string) bool {
+	step, ok := utils.ValidateTOTP(account.TOTPSecret, strings.TrimSpace(code), utils.GetCurrentTime())
+	return ok && database.UseTOTPStep(account.ID, step)
+}
+
+// 驗證 TOTP 驗證碼或備用碼
+func verifySecondFactor(context *fiber.Ctx, account *model.Account, code string) bool {
+	if verifyTOTP(account, code) {
+		return true
+	}
+
+	if database.UseRecoveryCode(account.ID, database.HashToken(normalizeRecoveryCode(code)), utils.GetCurrentTime()) {
+		recordSecurityEvent(context, account.ID, model.SecurityEventRecoveryCodeUsed, model.SecurityOutcomeSuccess, "")
+		return true
+	}
+
+	return false
+}
+
+// 登入第二步：驗證兩步驟驗證碼後發放權杖
+func VerifyTwoFactor(context *fiber.Ctx) error {
+	var myTwoFactor data.TwoFactor
+	_ = context.BodyParser(&myTwoFactor)
+
+	if myTwoFactor.ChallengeToken == "" || myTwoFactor.Code == "" {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	accountID, method, ok := parseChallengeToken(myTwoFactor.ChallengeToken)
+	if !ok {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	// 若 連續驗證失敗而鎖定 則 回請求過多，未鎖定時先記錄一次失敗，成功後再清除
+	failureKey := twoFactorFailureKey(accountID)
+	if !beginAttempt(context, failureKey) {
+		recordSecurityEvent(context, accountID, model.SecurityEventLogin, model.SecurityOutcomeFailure, method+"+2fa locked")
+		return context.SendStatus(fiber.StatusTooManyRequests)
+	}
+
+	account := database.GetAccountByID(uint64(accountID))
+	if account.ID == 0 || !account.TOTPEnabled || !isAccountEnabled(&account) {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	if !verifySecondFactor(context, &account, myTwoFactor.Code) {
+		recordSecurityEvent(context, account.ID, model.SecurityEventLogin, model.SecurityOutcomeFailure, method+"+2fa")
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	helper.Limiter.ResetFailures(failureKey)
+	recordSecurityEvent(context, account.ID, model.SecurityEventLogin, model.SecurityOutcomeSuccess, method+"+2fa")
+
+	return context.JSON(GenerateTokens(&account, context))
+}
+
+// 取得兩步驟驗證狀態
+func GetTwoFactorStatus(context *fiber.Ctx) error {
+	account := database.GetAccountByID(uint64(context.Locals("id").(float64)))
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	return context.JSON(fiber.Map{
+		"enabled":                account.TOTPEnabled,
+		"required":               configuration.RequireAdminTwoFactor && account.IsAdmin == 1,
+		"recoveryCodesRemaining": database.CountRecoveryCodes(account.ID),
+	})
+}
+
+// 開始設定兩步驟驗證，回傳金鑰與 otpauth:// 網址
+func EnrollTwoFactor(context *fiber.Ctx) error {
+	account := database.GetAccountByID(uint64(context.Locals("id").(float64)))
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	// 若 已啟用 則 須先停用才能重新設定
+	if account.TOTPEnabled {
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	secret := utils.GenerateTOTPSecret()
+	if !database.SetPendingTOTPSecret(account.ID, secret) {
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	return context.JSON(fiber.Map{
+		"secret": secret,
+		"uri":    utils.TOTPURI(configuration.TwoFactorIssuer, account.Email, secret),
+	})
+}
+
+// 以驗證碼確認設定並啟用兩步驟驗證，回傳備用碼(只顯示一次)
+func ConfirmTwoFactor(context *fiber.Ctx) error {
+	var myTwoFactor data.TwoFactor
+	_ = context.BodyParser(&myTwoFactor)
+
+	account := database.GetAccountByID(uint64(context.Locals("id").(float64)))
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+	if account.TOTPEnabled || account.TOTPSecret == "" {
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	step, ok := utils.ValidateTOTP(account.TOTPSecret, strings.TrimSpace(myTwoFactor.Code), utils.GetCurrentTime())
+	if !ok {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	codes, recoveryCodes := generateRecoveryCodes(account.ID)
+	if !database.EnableTOTP(account.ID, step, recoveryCodes) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	ClearPermissionCache(account.ID)
+	recordSecurityEvent(context, account.ID, model.SecurityEventTwoFactorEnabled, model.SecurityOutcomeSuccess, "")
+
+	return context.JSON(fiber.Map{"recoveryCodes": codes})
+}
+
+// 重新產生備用碼(需輸入目前的驗證碼)
+func RegenerateRecoveryCodes(context *fiber.Ctx) error {
+	var myTwoFactor data.TwoFactor
+	_ = context.BodyParser(&myTwoFactor)
+
+	account := database.GetAccountByID(uint64(context.Locals("id").(float64)))
+	if account.ID == 0 || !account.TOTPEnabled {
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	if !verifyTOTP(&account, myTwoFactor.Code) {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	codes, recoveryCodes := generateRecoveryCodes(account.ID)
+	if !database.ReplaceRecoveryCodes(account.ID, recoveryCodes) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return context.JSON(fiber.Map{"recoveryCodes": codes})
+}
+
+// 停用兩步驟驗證(需輸入驗證碼或備用碼)
+func DisableTwoFactor(context *fiber.Ctx) error {
+	var myTwoFactor data.TwoFactor
+	_ = context.BodyParser(&myTwoFactor)
+
+	account := database.GetAccountByID(uint64(context.Locals("id").(float64)))
+	if account.ID == 0 || !account.TOTPEnabled {
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	if !verifySecondFactor(context, &account, myTwoFactor.Code) {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	if !database.DisableTOTP(account.ID) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	ClearPermissionCache(account.ID)
+	recordSecurityEvent(context, account.ID, model.SecurityEventTwoFactorDisabled, model.SecurityOutcomeSuccess, "")
+
+	return context.SendStatus(fiber.StatusOK)
+}
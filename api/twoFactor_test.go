@@ -0,0 +1,64 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+)
+
+// 依 RFC 6238 計算指定週期的驗證碼
+func totpCodeAt(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+func TestVerifyTOTPRejectsReuse(t *testing.T) {
+	account := model.Account{Name: "Two Factor", Email: "totp-reuse@example.com", Status: model.Enabled}
+	if !database.AddAccount(&account) {
+		t.Fatal("add account")
+	}
+
+	secret := utils.GenerateTOTPSecret()
+	_, recoveryCodes := generateRecoveryCodes(account.ID)
+	if !database.SetPendingTOTPSecret(account.ID, secret) || !database.EnableTOTP(account.ID, 0, recoveryCodes) {
+		t.Fatal("enable totp")
+	}
+	account = database.GetAccountByID(uint64(account.ID))
+
+	current := utils.TOTPStep(utils.GetCurrentTime())
+	code := totpCodeAt(t, secret, current)
+
+	if !verifyTOTP(&account, code) {
+		t.Fatal("valid code rejected")
+	}
+	if verifyTOTP(&account, code) {
+		t.Fatal("code accepted twice")
+	}
+
+	// 已使用較新的驗證碼後，容許誤差內較舊的驗證碼也不可使用
+	if verifyTOTP(&account, totpCodeAt(t, secret, current-1)) {
+		t.Fatal("earlier code accepted after a newer one was used")
+	}
+}
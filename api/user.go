@@ -1,20 +1,228 @@
 package api
 
 import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/data"
 	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// 取得當前使用者
+// 沒有任何驗證方式的帳號，登入後可直接進行敏感操作的時間
+const reauthenticationWindow = 10 * time.Minute
+
+// 取得當前使用者與目前的工作階段(不會產生新權杖)
 func GetCurrentUser(context *fiber.Ctx) error {
 	id := uint64(context.Locals("id").(float64))
+	sessionID, _ := context.Locals("sid").(string)
 
 	account := database.GetAccountByID(id)
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
 
 	return context.JSON(fiber.Map{
-		"Token":   GenerateTokens(&account, context),
 		"Account": account,
-		"Athlete": database.GetAthleteByAccountID(uint(id)),
+		"Athlete": database.GetAthleteByAccountID(uint(id)).Public(),
+		"Session": database.GetSessionByID(sessionID),
 	})
 }
+
+// 修改個人資料
+func UpdateProfile(context *fiber.Ctx) error {
+	account := database.GetAccountByID(uint64(context.Locals("id").(float64)))
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	var myProfile data.Profile
+	_ = context.BodyParser(&myProfile)
+
+	if myProfile.Name == "" || len(myProfile.Name) > 64 {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	account.Name = myProfile.Name
+	if !database.UpdateAccountName(account.ID, account.Name) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	recordSecurityEvent(context, account.ID, model.SecurityEventProfileUpdated, model.SecurityOutcomeSuccess, "")
+
+	return context.JSON(account)
+}
+
+// 申請變更電子郵件，分別寄送確認連結到原電子郵件與新電子郵件
+func RequestEmailChange(context *fiber.Ctx) error {
+	account := database.GetAccountByID(uint64(context.Locals("id").(float64)))
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	var myEmailChange data.EmailChange
+	_ = context.BodyParser(&myEmailChange)
+
+	if myEmailChange.Email == "" || len(myEmailChange.Email) > 64 || strings.EqualFold(myEmailChange.Email, account.Email) {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// 若 超過每帳號寄送次數 則 回請求過多
+	if !AllowRequest(context, fmt.Sprintf("acct-id:%d", account.ID), configuration.RateLimitPerAccount) {
+		return context.SendStatus(fiber.StatusTooManyRequests)
+	}
+
+	if database.GetAccountByEmail(myEmailChange.Email).ID != 0 {
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	now := utils.GetCurrentTime()
+	oldToken := generateRandomString(32)
+	newToken := generateRandomString(32)
+
+	emailChange := model.EmailChange{
+		AccountID:    account.ID,
+		OldEmail:     account.Email,
+		NewEmail:     myEmailChange.Email,
+		OldTokenHash: database.HashToken(oldToken),
+		NewTokenHash: database.HashToken(newToken),
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(configuration.EmailChangeExpireDuration),
+	}
+	if !database.AddEmailChange(&emailChange) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	hours := int(configuration.EmailChangeExpireDuration.Hours())
+	oldBody := fmt.Sprintf("您的帳號申請將電子郵件變更為 %s，若為本人操作請點擊以下連結確認，連結將於 %d 小時後失效：\n\n%s",
+		emailChange.NewEmail, hours, emailChangeLink(oldToken))
+	newBody := fmt.Sprintf("請點擊以下連結確認此電子郵件，連結將於 %d 小時後失效：\n\n%s", hours, emailChangeLink(newToken))
+
+	if helper.Mailer.Send(emailChange.OldEmail, "Jimandy 變更電子郵件確認", oldBody) != nil ||
+		helper.Mailer.Send(emailChange.NewEmail, "Jimandy 變更電子郵件確認", newBody) != nil {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return context.SendStatus(fiber.StatusOK)
+}
+
+// 變更電子郵件確認連結
+func emailChangeLink(token string) string {
+	return fmt.Sprintf("%s/email-change?token=%s", configuration.SiteURL, url.QueryEscape(token))
+}
+
+// 確認變更電子郵件，新舊電子郵件都確認後完成變更
+func ConfirmEmailChange(context *fiber.Ctx) error {
+	var myMagicLink data.MagicLink
+	_ = context.BodyParser(&myMagicLink)
+
+	if myMagicLink.Token == "" {
+		return context.SendStatus(fiber.StatusBadRequest)
+	}
+
+	now := utils.GetCurrentTime()
+	emailChange := database.ConfirmEmailChange(database.HashToken(myMagicLink.Token), now)
+	if emailChange == nil {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	// 尚有一方未確認
+	if emailChange.OldConfirmedAt == nil || emailChange.NewConfirmedAt == nil {
+		return context.JSON(fiber.Map{"completed": false})
+	}
+
+	if !database.CompleteEmailChange(emailChange, now) {
+		return context.SendStatus(fiber.StatusConflict)
+	}
+
+	recordSecurityEvent(context, emailChange.AccountID, model.SecurityEventEmailChanged, model.SecurityOutcomeSuccess, emailChange.OldEmail+" -> "+emailChange.NewEmail)
+
+	return context.JSON(fiber.Map{"completed": true})
+}
+
+// 重新驗證身分失敗紀錄的鍵值
+func reauthenticationFailureKey(accountID uint) string {
+	return fmt.Sprintf("reauth-failure:%d", accountID)
+}
+
+// 重新驗證身分，依帳號擁有的驗證方式擇一驗證密碼、兩步驟驗證碼或通行金鑰
+// 帳號沒有任何驗證方式(只以外部身分提供者或免密碼連結登入)時，工作階段須為剛登入
+func verifyReauthentication(context *fiber.Ctx, account *model.Account, reauthentication *data.Reauthentication) bool {
+	switch {
+	case reauthentication.Password != "" && account.PasswordHash != "":
+		return utils.CheckPassword(account.PasswordHash, reauthentication.Password)
+	case reauthentication.Code != "" && account.TOTPEnabled:
+		return verifySecondFactor(context, account, reauthentication.Code)
+	case reauthentication.CeremonyID != "" && len(reauthentication.Credential) > 0:
+		return verifyPasskeyReauthentication(context, account, reauthentication)
+	case account.PasswordHash == "" && !account.TOTPEnabled && len(database.GetWebAuthnCredentials(account.ID)) == 0:
+		sessionID, _ := context.Locals("sid").(string)
+		session := database.GetSessionByID(sessionID)
+		return session != nil && utils.GetCurrentTime().Sub(session.FirstSeenAt) < reauthenticationWindow
+	}
+
+	return false
+}
+
+// 刪除帳號(需重新驗證身分)，有設定緩衝期時先登出所有裝置並於緩衝期後刪除
+func DeleteMe(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+
+	account := database.GetAccountByID(uint64(accountID))
+	if account.ID == 0 {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	var myReauthentication data.Reauthentication
+	_ = context.BodyParser(&myReauthentication)
+
+	// 若 連續驗證失敗而鎖定 則 回請求過多，未鎖定時先記錄一次失敗，成功後再清除
+	failureKey := reauthenticationFailureKey(accountID)
+	if !beginAttempt(context, failureKey) {
+		return context.SendStatus(fiber.StatusTooManyRequests)
+	}
+
+	if !verifyReauthentication(context, &account, &myReauthentication) {
+		recordSecurityEvent(context, accountID, model.SecurityEventAccountDeletion, model.SecurityOutcomeFailure, "reauthentication")
+		return context.SendStatus(fiber.StatusForbidden)
+	}
+
+	helper.Limiter.ResetFailures(failureKey)
+
+	if configuration.AccountDeletionGracePeriod <= 0 {
+		recordSecurityEvent(context, accountID, model.SecurityEventAccountDeletion, model.SecurityOutcomeSuccess, "deleted")
+		if !database.DeleteAccount(accountID) {
+			return context.SendStatus(fiber.StatusInternalServerError)
+		}
+		return context.SendStatus(fiber.StatusOK)
+	}
+
+	deletionAt := utils.GetCurrentTime().Add(configuration.AccountDeletionGracePeriod)
+	if !database.ScheduleAccountDeletion(accountID, &deletionAt) || !database.RevokeAllUserTokens(accountID) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	recordSecurityEvent(context, accountID, model.SecurityEventAccountDeletion, model.SecurityOutcomeSuccess, "scheduled "+deletionAt.Format(time.RFC3339))
+
+	return context.JSON(fiber.Map{"deletionScheduledAt": deletionAt})
+}
+
+// 取消刪除帳號(緩衝期內重新登入後呼叫)
+func CancelDeleteMe(context *fiber.Ctx) error {
+	accountID := uint(context.Locals("id").(float64))
+
+	if !database.ScheduleAccountDeletion(accountID, nil) {
+		return context.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	recordSecurityEvent(context, accountID, model.SecurityEventAccountDeletion, model.SecurityOutcomeSuccess, "cancelled")
+
+	return context.SendStatus(fiber.StatusOK)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/helper"
+)
+
+// 執行管理指令，回傳結束代碼
+func runCommand(args []string) int {
+	switch args[0] {
+	case "strava-subscription":
+		return runStravaSubscriptionCommand(args[1:])
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+	fmt.Fprintln(os.Stderr, "usage: main strava-subscription <list|create|delete> [flags]")
+	return 2
+}
+
+// 管理 Strava 推播訂閱
+func runStravaSubscriptionCommand(args []string) int {
+	flags := flag.NewFlagSet("strava-subscription", flag.ContinueOnError)
+	clientID := flags.Uint("client-id", configuration.StravaClientID, "Strava client ID")
+	clientSecret := flags.String("client-secret", configuration.StravaClientSecret, "Strava client secret")
+	callbackURL := flags.String("callback-url", configuration.StravaWebhookCallbackURL, "callback URL for create")
+	subscriptionID := flags.Uint64("id", 0, "subscription ID for delete")
+
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: main strava-subscription <list|create|delete> [flags]")
+		flags.PrintDefaults()
+		return 2
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *clientID == 0 || *clientSecret == "" {
+		fmt.Fprintln(os.Stderr, "-client-id and -client-secret are required")
+		return 2
+	}
+
+	switch args[0] {
+	case "list":
+		subscriptions, err := helper.GetStravaSubscriptions(*clientID, *clientSecret)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, subscription := range subscriptions {
+			fmt.Printf("%d\t%s\t%s\n", subscription.ID, subscription.CallbackURL, subscription.CreatedAt)
+		}
+	case "create":
+		if configuration.StravaWebhookVerifyToken == "" {
+			fmt.Fprintln(os.Stderr, "STRAVAWEBHOOKVERIFYTOKEN must be set")
+			return 2
+		}
+		subscription, err := helper.CreateStravaSubscription(*clientID, *clientSecret, *callbackURL, configuration.StravaWebhookVerifyToken)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("created subscription %d, set STRAVAWEBHOOKSUBSCRIPTIONID=%d\n", subscription.ID, subscription.ID)
+	case "delete":
+		if *subscriptionID == 0 {
+			fmt.Fprintln(os.Stderr, "-id is required")
+			return 2
+		}
+		if err := helper.DeleteStravaSubscription(*clientID, *clientSecret, *subscriptionID); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("deleted subscription %d\n", *subscriptionID)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown action %q\n", args[0])
+		return 2
+	}
+
+	return 0
+}
@@ -1,15 +1,113 @@
 package configuration
 
 import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 var (
-	Connectionstring string // 資料庫連線字串
-	ExecutPath       string // 執行檔路徑
-	JWTKey           []byte // 權杖金鑰
+	Connectionstring       string // 資料庫連線字串
+	ListenConnectionstring string // 接收資料庫通知的連線字串(連線池不支援 LISTEN 時使用直連)
+	ExecutPath             string // 執行檔路徑
+	JWTKey                 []byte // 權杖金鑰(HS256，未設定簽章金鑰時使用)
+	JWTIssuer              string // 權杖發行者
+	JWTAudience            string // 權杖對象
+	TokenHashKey           []byte // 權杖雜湊金鑰(未設定時使用 KEY)
+	SiteURL                string // 網站網址(用於產生信件連結)
+
+	SMTPHost     string // 郵件伺服器位址
+	SMTPPort     int    // 郵件伺服器連接埠
+	SMTPUsername string // 郵件伺服器帳號
+	SMTPPassword string // 郵件伺服器密碼
+	MailFrom     string // 寄件者
+
+	MagicLinkExpireDuration      time.Duration // 免密碼登入連結逾時
+	ActivationLinkExpireDuration time.Duration // 帳號啟用連結逾時
+	EmailChangeExpireDuration    time.Duration // 變更電子郵件確認連結逾時
+	AccountDeletionGracePeriod   time.Duration // 刪除帳號前的緩衝期，0 表示立即刪除
+
+	AccessTokenExpireDuration  time.Duration // 存取權杖逾時
+	RefreshTokenExpireDuration time.Duration // 刷新權杖逾時，每次刷新重新計算
+	SessionIdleTimeout         time.Duration // 登入閒置逾時，0 表示不限制
+	SessionMaxAge              time.Duration // 登入最長存活時間，0 表示不限制
+
+	IdentityProviders []IdentityProvider // 外部身分提供者
+
+	JWTSigningKeys []SigningKey // 權杖簽章金鑰
+
+	EncryptionKeys []EncryptionKey // 加密 Strava 憑證的金鑰加密金鑰
+
+	RateLimitStore          string        // 限流狀態儲存方式 memory 或 postgres
+	RateLimitWindow         time.Duration // 限流滑動視窗
+	RateLimitPerIP          int           // 每個 IP 在視窗內可呼叫登入相關 API 的次數
+	RateLimitPerAccount     int           // 每個帳號在視窗內可呼叫登入相關 API 的次數
+	LoginLockoutThreshold   int           // 登入失敗幾次後開始鎖定
+	LoginLockoutDuration    time.Duration // 第一次鎖定時間，之後每次失敗加倍
+	LoginLockoutMaxDuration time.Duration // 最長鎖定時間
+
+	TwoFactorIssuer            string        // 驗證器 App 顯示的發行者名稱
+	TwoFactorChallengeDuration time.Duration // 兩步驟驗證登入權杖逾時
+	RequireAdminTwoFactor      bool          // 管理員是否必須啟用兩步驟驗證
+
+	WebAuthnRPID      string   // 通行金鑰依賴方 ID(網域)，未設定時使用網站網址的網域
+	WebAuthnRPName    string   // 通行金鑰依賴方顯示名稱
+	WebAuthnRPOrigins []string // 允許的來源，未設定時使用網站網址
+
+	SecurityEventRetention time.Duration // 安全事件保留期限，0 表示永久保留
+
+	StravaClientID     uint     // Strava 應用程式用戶端 ID
+	StravaClientSecret string   // Strava 應用程式用戶端密碼
+	StravaRedirectURL  string   // Strava 授權後導回的網址，未設定時使用網站網址
+	StravaScopes       []string // 連結運動員時要求且必須取得的授權範圍
+
+	StravaRateLimitMaxWait time.Duration // Strava 配額用盡時最多等待多久，超過則直接回傳錯誤
+
+	StravaWebhookVerifyToken    string        // Strava 推播訂閱驗證用的權杖
+	StravaWebhookSubscriptionID uint64        // Strava 推播訂閱 ID，只接受此訂閱的事件
+	StravaWebhookCallbackURL    string        // Strava 推播訂閱回呼網址，未設定時使用網站網址
+	StravaWebhookRateLimitPerIP int           // 每個 IP 在限流時間窗內可送出的 Strava 推播事件數
+	StravaEventRetention        time.Duration // Strava 推播事件保留期限
+
+	ExportPath           string        // 個人資料匯出檔存放路徑
+	ExportExpireDuration time.Duration // 個人資料匯出檔下載期限
+
+	CleanupInterval  time.Duration // 清除過期資料的間隔
+	CleanupBatchSize int           // 每批刪除的筆數
 )
 
+// 權杖簽章金鑰設定
+// 只有一把金鑰可為 Active(用於簽章)，其餘金鑰只用於驗證，以便輪替
+type SigningKey struct {
+	KID        string // 金鑰 ID
+	Algorithm  string // 演算法 RS256 或 EdDSA
+	PrivateKey string // PEM 格式私鑰
+	PublicKey  string // PEM 格式公鑰(已停用的金鑰可只保留公鑰)
+	Active     bool   // 是否用於簽章
+}
+
+// 金鑰加密金鑰設定
+// 只有一把金鑰可為 Active(用於加密)，其餘金鑰只用於解密，以便輪替
+type EncryptionKey struct {
+	KID    string // 金鑰 ID
+	Key    string // Base64 編碼的 32 bytes 金鑰
+	Active bool   // 是否用於加密
+}
+
+// 外部身分提供者設定
+type IdentityProvider struct {
+	Name         string   // 識別名稱(網址使用)
+	Type         string   // 類型 oidc 或 strava
+	Issuer       string   // OpenID Connect 發行者網址
+	ClientID     string   // 用戶端 ID
+	ClientSecret string   // 用戶端密碼
+	RedirectURL  string   // 授權後導回網址
+	Scopes       []string // 授權範圍
+}
+
 // 讀取設定檔
 func ReadConfiguration() {
 	viper.AutomaticEnv()
@@ -19,8 +117,166 @@ func ReadConfiguration() {
 	viper.AddConfigPath("..")
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
+
+	viper.SetDefault("SITEURL", "https://jimandy-growth.com")
+	viper.SetDefault("SMTPPORT", 587)
+	viper.SetDefault("JWTISSUER", "https://jimandy-growth.com")
+	viper.SetDefault("JWTAUDIENCE", "jimandy-website")
+	viper.SetDefault("MAGICLINKEXPIRE", "15m")
+	viper.SetDefault("ACTIVATIONLINKEXPIRE", "24h")
+	viper.SetDefault("EMAILCHANGEEXPIRE", "24h")
+	viper.SetDefault("ACCOUNTDELETIONGRACEPERIOD", "0")
+	viper.SetDefault("ACCESSTOKENEXPIRE", "1h")
+	viper.SetDefault("REFRESHTOKENEXPIRE", "720h")
+	viper.SetDefault("SESSIONIDLETIMEOUT", "0")
+	viper.SetDefault("SESSIONMAXAGE", "2160h")
+	viper.SetDefault("RATELIMITSTORE", "memory")
+	viper.SetDefault("RATELIMITWINDOW", "1m")
+	viper.SetDefault("RATELIMITPERIP", 20)
+	viper.SetDefault("RATELIMITPERACCOUNT", 10)
+	viper.SetDefault("LOGINLOCKOUTTHRESHOLD", 5)
+	viper.SetDefault("LOGINLOCKOUTDURATION", "1m")
+	viper.SetDefault("LOGINLOCKOUTMAXDURATION", "1h")
+	viper.SetDefault("TWOFACTORISSUER", "Jimandy")
+	viper.SetDefault("TWOFACTORCHALLENGEEXPIRE", "5m")
+	viper.SetDefault("REQUIREADMINTWOFACTOR", false)
+	viper.SetDefault("WEBAUTHNRPNAME", "Jimandy")
+	viper.SetDefault("SECURITYEVENTRETENTION", "8760h")
+	viper.SetDefault("STRAVASCOPES", []string{"read", "activity:read_all"})
+	viper.SetDefault("STRAVARATELIMITMAXWAIT", "30s")
+	viper.SetDefault("STRAVAWEBHOOKRATELIMITPERIP", 300)
+	viper.SetDefault("STRAVAEVENTRETENTION", "720h")
+	viper.SetDefault("EXPORTEXPIRE", "72h")
+	viper.SetDefault("CLEANUPINTERVAL", "1h")
+	viper.SetDefault("CLEANUPBATCHSIZE", 1000)
+
 	_ = viper.ReadInConfig()
 
 	Connectionstring = viper.GetString("CONNECTIONSTRING")
+	ListenConnectionstring = viper.GetString("LISTENCONNECTIONSTRING")
+	if ListenConnectionstring == "" {
+		ListenConnectionstring = Connectionstring
+	}
 	JWTKey = []byte(viper.GetString("KEY"))
+	JWTIssuer = viper.GetString("JWTISSUER")
+	JWTAudience = viper.GetString("JWTAUDIENCE")
+	TokenHashKey = []byte(viper.GetString("TOKENHASHKEY"))
+	if len(TokenHashKey) == 0 {
+		TokenHashKey = JWTKey
+	}
+	SiteURL = viper.GetString("SITEURL")
+
+	SMTPHost = viper.GetString("SMTPHOST")
+	SMTPPort = viper.GetInt("SMTPPORT")
+	SMTPUsername = viper.GetString("SMTPUSERNAME")
+	SMTPPassword = viper.GetString("SMTPPASSWORD")
+	MailFrom = viper.GetString("MAILFROM")
+
+	MagicLinkExpireDuration = viper.GetDuration("MAGICLINKEXPIRE")
+	ActivationLinkExpireDuration = viper.GetDuration("ACTIVATIONLINKEXPIRE")
+	EmailChangeExpireDuration = viper.GetDuration("EMAILCHANGEEXPIRE")
+	AccountDeletionGracePeriod = viper.GetDuration("ACCOUNTDELETIONGRACEPERIOD")
+
+	AccessTokenExpireDuration = viper.GetDuration("ACCESSTOKENEXPIRE")
+	RefreshTokenExpireDuration = viper.GetDuration("REFRESHTOKENEXPIRE")
+	SessionIdleTimeout = viper.GetDuration("SESSIONIDLETIMEOUT")
+	SessionMaxAge = viper.GetDuration("SESSIONMAXAGE")
+
+	RateLimitStore = viper.GetString("RATELIMITSTORE")
+	RateLimitWindow = viper.GetDuration("RATELIMITWINDOW")
+	RateLimitPerIP = viper.GetInt("RATELIMITPERIP")
+	RateLimitPerAccount = viper.GetInt("RATELIMITPERACCOUNT")
+	LoginLockoutThreshold = viper.GetInt("LOGINLOCKOUTTHRESHOLD")
+	LoginLockoutDuration = viper.GetDuration("LOGINLOCKOUTDURATION")
+	LoginLockoutMaxDuration = viper.GetDuration("LOGINLOCKOUTMAXDURATION")
+
+	TwoFactorIssuer = viper.GetString("TWOFACTORISSUER")
+	TwoFactorChallengeDuration = viper.GetDuration("TWOFACTORCHALLENGEEXPIRE")
+	RequireAdminTwoFactor = viper.GetBool("REQUIREADMINTWOFACTOR")
+
+	WebAuthnRPID = viper.GetString("WEBAUTHNRPID")
+	WebAuthnRPName = viper.GetString("WEBAUTHNRPNAME")
+	WebAuthnRPOrigins = viper.GetStringSlice("WEBAUTHNRPORIGINS")
+	if len(WebAuthnRPOrigins) == 0 {
+		WebAuthnRPOrigins = []string{SiteURL}
+	}
+	if WebAuthnRPID == "" {
+		if siteURL, err := url.Parse(SiteURL); err == nil {
+			WebAuthnRPID = siteURL.Hostname()
+		}
+	}
+
+	SecurityEventRetention = viper.GetDuration("SECURITYEVENTRETENTION")
+
+	StravaClientID = viper.GetUint("STRAVACLIENTID")
+	StravaClientSecret = viper.GetString("STRAVACLIENTSECRET")
+	StravaRedirectURL = viper.GetString("STRAVAREDIRECTURL")
+	if StravaRedirectURL == "" {
+		StravaRedirectURL = SiteURL + "/strava/callback"
+	}
+	StravaScopes = viper.GetStringSlice("STRAVASCOPES")
+
+	StravaRateLimitMaxWait = viper.GetDuration("STRAVARATELIMITMAXWAIT")
+
+	StravaWebhookVerifyToken = viper.GetString("STRAVAWEBHOOKVERIFYTOKEN")
+	StravaWebhookSubscriptionID = viper.GetUint64("STRAVAWEBHOOKSUBSCRIPTIONID")
+	StravaWebhookCallbackURL = viper.GetString("STRAVAWEBHOOKCALLBACKURL")
+	if StravaWebhookCallbackURL == "" {
+		StravaWebhookCallbackURL = SiteURL + "/api/strava/webhook"
+	}
+	StravaWebhookRateLimitPerIP = viper.GetInt("STRAVAWEBHOOKRATELIMITPERIP")
+	StravaEventRetention = viper.GetDuration("STRAVAEVENTRETENTION")
+
+	ExportPath = viper.GetString("EXPORTPATH")
+	if ExportPath == "" {
+		ExportPath = filepath.Join(ExecutPath, "exports")
+	}
+	ExportExpireDuration = viper.GetDuration("EXPORTEXPIRE")
+
+	CleanupInterval = viper.GetDuration("CLEANUPINTERVAL")
+	CleanupBatchSize = viper.GetInt("CLEANUPBATCHSIZE")
+
+	IdentityProviders = nil
+	_ = viper.UnmarshalKey("IDENTITYPROVIDERS", &IdentityProviders)
+
+	JWTSigningKeys = nil
+	_ = viper.UnmarshalKey("JWTSIGNINGKEYS", &JWTSigningKeys)
+
+	EncryptionKeys = nil
+	_ = viper.UnmarshalKey("ENCRYPTIONKEYS", &EncryptionKeys)
+}
+
+// 檢查設定值，不合法的設定會使服務無法正常運作，啟動時即回傳錯誤
+func ValidateConfiguration() error {
+	positiveInts := []struct {
+		name  string
+		value int
+	}{
+		{"RATELIMITPERIP", RateLimitPerIP},
+		{"RATELIMITPERACCOUNT", RateLimitPerAccount},
+		{"LOGINLOCKOUTTHRESHOLD", LoginLockoutThreshold},
+		{"CLEANUPBATCHSIZE", CleanupBatchSize},
+		{"STRAVAWEBHOOKRATELIMITPERIP", StravaWebhookRateLimitPerIP},
+	}
+	for _, setting := range positiveInts {
+		if setting.value <= 0 {
+			return fmt.Errorf("%s must be greater than 0", setting.name)
+		}
+	}
+
+	positiveDurations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"RATELIMITWINDOW", RateLimitWindow},
+		{"LOGINLOCKOUTDURATION", LoginLockoutDuration},
+		{"LOGINLOCKOUTMAXDURATION", LoginLockoutMaxDuration},
+	}
+	for _, setting := range positiveDurations {
+		if setting.value <= 0 {
+			return fmt.Errorf("%s must be greater than 0", setting.name)
+		}
+	}
+
+	return nil
 }
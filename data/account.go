@@ -0,0 +1,21 @@
+package data
+
+import "encoding/json"
+
+// 個人資料
+type Profile struct {
+	Name string
+}
+
+// 重新驗證身分(刪除帳號等敏感操作)，擇一提供密碼、驗證碼或通行金鑰
+type Reauthentication struct {
+	Password   string          // 密碼
+	Code       string          // 兩步驟驗證碼或備用碼
+	CeremonyID string          // 開始通行金鑰驗證時取得的流程 ID
+	Credential json.RawMessage // 瀏覽器回傳的 PublicKeyCredential
+}
+
+// 變更電子郵件
+type EmailChange struct {
+	Email string
+}
@@ -0,0 +1,11 @@
+package data
+
+// 帳號狀態
+type AccountStatus struct {
+	Status *int8 // 0停用 1啟用，未提供時為 nil
+}
+
+// 帳號管理員身分
+type AccountAdmin struct {
+	IsAdmin *bool // 未提供時為 nil
+}
@@ -0,0 +1,10 @@
+package data
+
+import "time"
+
+// 個人存取金鑰
+type APIKey struct {
+	Name      string
+	Scopes    []string   // 授權範圍，須為帳號擁有的權限
+	ExpiresAt *time.Time // 到期時間，空值表示不過期
+}
@@ -0,0 +1,6 @@
+package data
+
+// 裝置(工作階段)
+type Device struct {
+	Name string
+}
@@ -0,0 +1,17 @@
+package data
+
+// 外部身分提供者回傳的使用者身分
+type ExternalIdentity struct {
+	Provider      string
+	Type          string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// 外部身分提供者授權回呼
+type IdentityCallback struct {
+	Code  string
+	State string
+}
@@ -3,4 +3,10 @@ package data
 type Login struct {
 	Name     string
 	Email    string
-}
\ No newline at end of file
+	Password string
+}
+
+// 免密碼登入連結
+type MagicLink struct {
+	Token string
+}
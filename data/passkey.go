@@ -0,0 +1,10 @@
+package data
+
+import "encoding/json"
+
+// 通行金鑰驗證流程回應
+type Passkey struct {
+	CeremonyID string          // 開始流程時取得的流程 ID
+	Name       string          // 顯示名稱(註冊時使用)
+	Credential json.RawMessage // 瀏覽器回傳的 PublicKeyCredential
+}
@@ -0,0 +1,14 @@
+package data
+
+import "time"
+
+// 安全事件查詢條件，零值表示不限制
+type SecurityEventFilter struct {
+	AccountID uint
+	ActorID   uint
+	Type      string
+	Outcome   string
+	IP        string
+	From      time.Time
+	To        time.Time
+}
@@ -3,11 +3,14 @@ package data
 type Token struct {
 	AccessToken  string  `json:"access_token"`
 	RefreshToken string  `json:"refresh_token"`
+	ExpiresAt    int64   `json:"expires_at"` // 通行權杖到期時間(Unix)
 	Athlete      Athlete `json:"athlete"`
 }
 
 type Athlete struct {
 	AthleteID uint64 `json:"id"`
+	FirstName string `json:"firstname"`
+	LastName  string `json:"lastname"`
 }
 
 type Activity struct {
@@ -52,5 +55,50 @@ type Activities struct {
 	AverageWatts       float32  `json:"average_watts"`
 	MaxWatts           float32  `json:"max_watts"`
 	AverageTemperature int      `json:"average_temp"`
+	Visibility         string   `json:"visibility"`
 	RouteMap           RouteMap `json:"map"`
 }
+
+// 授權檢視活動的帳號
+type AthleteViewer struct {
+	Email string
+}
+
+// 運動員活動公開設定
+type AthleteVisibility struct {
+	IsPublic bool
+}
+
+// Strava 推播事件
+type StravaEvent struct {
+	ObjectType     string                 `json:"object_type"`
+	ObjectID       uint64                 `json:"object_id"`
+	AspectType     string                 `json:"aspect_type"`
+	Updates        map[string]interface{} `json:"updates"`
+	OwnerID        uint64                 `json:"owner_id"`
+	SubscriptionID uint64                 `json:"subscription_id"`
+	EventTime      int64                  `json:"event_time"`
+}
+
+// Strava 推播訂閱
+type StravaSubscription struct {
+	ID          uint64 `json:"id"`
+	CallbackURL string `json:"callback_url"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// Strava 授權回呼
+type StravaCallback struct {
+	Code  string
+	State string
+	Scope string // Strava 導回時附帶的實際授權範圍，以逗號分隔
+}
+
+// 回傳給前端的運動員(不含 Strava 憑證)
+type PublicAthlete struct {
+	ID        uint64
+	AccountID uint
+	IsPublic  bool // 是否公開 Strava 可見度為所有人的活動
+	Connected bool // Strava 授權是否有效
+}
@@ -0,0 +1,7 @@
+package data
+
+// 兩步驟驗證
+type TwoFactor struct {
+	ChallengeToken string // 登入第一步取得的驗證權杖
+	Code           string // 驗證碼或備用碼
+}
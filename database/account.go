@@ -1,6 +1,14 @@
 package database
 
-import "Jimandy-Website-Backend/model"
+import (
+	"os"
+	"strings"
+	"time"
+
+	"Jimandy-Website-Backend/model"
+
+	"gorm.io/gorm"
+)
 
 // 依 主鍵 取得 帳號
 func GetAccountByID(id uint64) (account model.Account) {
@@ -13,12 +21,129 @@ func GetAccountByID(id uint64) (account model.Account) {
 func GetAccountByEmail(email string) (account model.Account) {
 	db.Where("email = ?", email).First(&account)
 
-	return 
+	return
+}
+
+// 依名稱或電子郵件搜尋帳號並分頁，status 為 nil 時不限狀態
+func SearchAccounts(keyword string, status *int8, offset int, limit int) (accounts []model.Account, total int64) {
+	query := db.Model(&model.Account{})
+	if keyword != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(keyword) + "%"
+		query = query.Where("name ILIKE ? OR email ILIKE ?", pattern, pattern)
+	}
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	query = query.Session(&gorm.Session{}) // 總筆數與分頁查詢共用條件
+	query.Count(&total)
+	query.Order("id").Offset(offset).Limit(limit).Find(&accounts)
+
+	return
+}
+
+// 設定帳號是否為管理員
+func SetAccountAdmin(id uint, isAdmin bool) bool {
+	value := 0
+	if isAdmin {
+		value = 1
+	}
+
+	return db.Model(&model.Account{}).Where("id = ?", id).Update("is_admin", value).RowsAffected == 1
+}
+
+// 修改帳號名稱(只更新名稱欄位)
+func UpdateAccountName(id uint, name string) bool {
+	return db.Model(&model.Account{}).Where("id = ?", id).Update("name", name).Error == nil
 }
 
 // 新增帳號
-func AddAccount(account model.Account) bool {
-	return db.Save(&account).Error == nil
+func AddAccount(account *model.Account) bool {
+	return db.Create(account).Error == nil
+}
+
+// 啟用尚未啟用的帳號(電子郵件須與啟用連結相同)
+func ActivateAccount(id uint, email string) bool {
+	return db.Model(&model.Account{}).
+		Where("id = ? AND email = ? AND status = ?", id, email, model.UnActive).
+		Update("status", model.Enabled).RowsAffected == 1
+}
+
+// 停用帳號並撤銷所有工作階段
+func DisableAccount(id uint) bool {
+	if db.Model(&model.Account{}).Where("id = ?", id).Update("status", model.Disabled).Error != nil {
+		return false
+	}
+
+	return RevokeAllUserTokens(id)
+}
+
+// 重新啟用已停用的帳號，尚未完成電子郵件驗證的帳號不可由此啟用
+func EnableAccount(id uint) bool {
+	return db.Model(&model.Account{}).Where("id = ? AND status = ?", id, model.Disabled).Update("status", model.Enabled).RowsAffected == 1
+}
+
+// 設定或取消帳號預定刪除時間
+func ScheduleAccountDeletion(id uint, deletionAt *time.Time) bool {
+	return db.Model(&model.Account{}).Where("id = ?", id).Update("deletion_scheduled_at", deletionAt).RowsAffected == 1
+}
+
+// 刪除帳號與所有相關資料(運動員、活動、圈數、權杖等)
+func DeleteAccount(id uint) bool {
+	athleteIDs := db.Model(&model.Athlete{}).Select("id").Where("account_id = ?", id)
+
+	var exportFiles []string
+	db.Model(&model.DataExport{}).Where("account_id = ? AND file_path <> ''", id).Pluck("file_path", &exportFiles)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		deletions := []*gorm.DB{
+			tx.Where("athlete_id IN (?)", athleteIDs).Delete(&model.Lap{}),
+			tx.Where("athlete_id IN (?)", athleteIDs).Delete(&model.Activity{}),
+			tx.Where("athlete_id IN (?) OR account_id = ?", athleteIDs, id).Delete(&model.AthleteViewer{}),
+			tx.Where("account_id = ?", id).Delete(&model.Athlete{}),
+			tx.Where("account_id = ?", id).Delete(&model.Token{}),
+			tx.Where("account_id = ?", id).Delete(&model.Session{}),
+			tx.Where("account_id = ?", id).Delete(&model.Identity{}),
+			tx.Where("account_id = ?", id).Delete(&model.RecoveryCode{}),
+			tx.Where("account_id = ?", id).Delete(&model.WebAuthnCredential{}),
+			tx.Where("account_id = ?", id).Delete(&model.APIKey{}),
+			tx.Where("account_id = ?", id).Delete(&model.EmailChange{}),
+			tx.Where("account_id = ?", id).Delete(&model.DataExport{}),
+			tx.Exec("DELETE FROM account_roles WHERE account_id = ?", id),
+			tx.Unscoped().Delete(&model.Account{}, id),
+		}
+		for _, deletion := range deletions {
+			if deletion.Error != nil {
+				return deletion.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false
+	}
+
+	for _, exportFile := range exportFiles {
+		_ = os.Remove(exportFile)
+	}
+
+	// 已發出的 access token 立即失效
+	publishAccountRevocation(id, time.Now())
+
+	return true
+}
+
+// 刪除已超過預定刪除時間的帳號，回傳刪除數量
+func PurgeScheduledAccountDeletions(now time.Time) (count int64) {
+	var ids []uint
+	db.Model(&model.Account{}).Where("deletion_scheduled_at < ?", now).Pluck("id", &ids)
+
+	for _, id := range ids {
+		if DeleteAccount(id) {
+			count++
+		}
+	}
+	return
 }
 
 // 更新帳號
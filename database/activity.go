@@ -6,6 +6,9 @@ import (
 
 	"Jimandy-Website-Backend/data"
 	"Jimandy-Website-Backend/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // 取得運動員最近一次的活動
@@ -29,6 +32,34 @@ func GetActivitiesByAthleteID(athleteID uint64) (activities []model.Activity) {
 	return
 }
 
+// 依運動員主鍵取得公開活動
+func GetPublicActivitiesByAthleteID(athleteID uint64) (activities []model.Activity) {
+	db.Where("athlete_id = ? AND visibility = ?", athleteID, model.VisibilityEveryone).Order("date DESC").Find(&activities)
+
+	return
+}
+
+// 是否有尚未取得可見度的活動(可見度欄位新增前爬取的活動)
+func HasActivitiesWithoutVisibility(athleteID uint64) bool {
+	var count int64
+	db.Model(&model.Activity{}).Where("athlete_id = ? AND (visibility IS NULL OR visibility = '')", athleteID).Count(&count)
+
+	return count > 0
+}
+
+// 取得有尚未取得可見度活動的運動員主鍵
+func GetAthleteIDsWithoutActivityVisibility() (athleteIDs []uint64) {
+	db.Model(&model.Activity{}).Distinct("athlete_id").Where("visibility IS NULL OR visibility = ''").Pluck("athlete_id", &athleteIDs)
+
+	return
+}
+
+// 將尚未取得可見度的活動設為指定的可見度
+func SetMissingActivityVisibility(athleteID uint64, visibility string) bool {
+	return db.Model(&model.Activity{}).Where("athlete_id = ? AND (visibility IS NULL OR visibility = '')", athleteID).
+		Update("visibility", visibility).Error == nil
+}
+
 // 取得跑步活動圈數
 func GetLaps(athleteID uint64, activityID string) (runLaps []model.Lap) {
 	db.Where("athlete_id = ? AND activity_id = ?", athleteID, activityID).Find(&runLaps)
@@ -36,43 +67,74 @@ func GetLaps(athleteID uint64, activityID string) (runLaps []model.Lap) {
 	return
 }
 
-// 新增活動
+// 取得運動員所有圈數
+func GetLapsByAthleteID(athleteID uint64) (laps []model.Lap) {
+	db.Where("athlete_id = ?", athleteID).Order("activity_id, id").Find(&laps)
+
+	return
+}
+
+// 新增活動，已存在的活動以 Strava 上的內容更新
 func AddActivity(activities []data.Activities) bool {
 	var myActivities []model.Activity
 	result := true
 
 	for _, actitvity := range activities {
-		date, _ := time.Parse(time.RFC3339, actitvity.Date)
-
-		myActivities = append(myActivities, model.Activity{
-			ID:                 actitvity.ActivityID,
-			AthleteID:          actitvity.Athlete.AthleteID,
-			Name:               actitvity.Name,
-			SportType:          actitvity.SportType,
-			Date:               date,
-			ElapsedTime:        actitvity.ElapsedTime,
-			MovingTime:         actitvity.MovingTime,
-			Distance:           int(math.Round(float64(actitvity.Distance))),
-			TotalElevationGain: int(math.Round(float64(actitvity.TotalElevationGain))),
-			AverageSpeed:       actitvity.AverageSpeed * 3.6,
-			MaxSpeed:           actitvity.MaxSpeed * 3.6,
-			AverageCadence:     int(math.Round(float64(actitvity.AverageCadence * 2))),
-			AverageHeartrate:   int(math.Round(float64(actitvity.AverageHeartrate))),
-			MaxHeartrate:       int(math.Round(float64(actitvity.MaxHeartrate))),
-			AverageWatts:       int(math.Round(float64(actitvity.AverageWatts))),
-			MaxWatts:           int(math.Round(float64(actitvity.MaxWatts))),
-			AverageTemperature: actitvity.AverageTemperature,
-			Polyline:           actitvity.RouteMap.Polyline,
-		})
+		myActivities = append(myActivities, toActivity(actitvity))
 	}
 
-	if len(myActivities) > 0 && db.Create(&myActivities).Error != nil {
+	if len(myActivities) > 0 && db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&myActivities).Error != nil {
 		result = false
 	}
 
 	return result
 }
 
+// 新增或更新單一活動
+func SaveActivity(activity data.Activities) bool {
+	myActivity := toActivity(activity)
+
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&myActivity).Error == nil
+}
+
+// 刪除活動與其圈數
+func DeleteActivity(athleteID uint64, activityID uint64) bool {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("athlete_id = ? AND activity_id = ?", athleteID, activityID).Delete(&model.Lap{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("athlete_id = ? AND id = ?", athleteID, activityID).Delete(&model.Activity{}).Error
+	}) == nil
+}
+
+// 將 Strava 活動轉換為活動紀錄
+func toActivity(actitvity data.Activities) model.Activity {
+	date, _ := time.Parse(time.RFC3339, actitvity.Date)
+
+	return model.Activity{
+		ID:                 actitvity.ActivityID,
+		AthleteID:          actitvity.Athlete.AthleteID,
+		Name:               actitvity.Name,
+		SportType:          actitvity.SportType,
+		Date:               date,
+		ElapsedTime:        actitvity.ElapsedTime,
+		MovingTime:         actitvity.MovingTime,
+		Distance:           int(math.Round(float64(actitvity.Distance))),
+		TotalElevationGain: int(math.Round(float64(actitvity.TotalElevationGain))),
+		AverageSpeed:       actitvity.AverageSpeed * 3.6,
+		MaxSpeed:           actitvity.MaxSpeed * 3.6,
+		AverageCadence:     int(math.Round(float64(actitvity.AverageCadence * 2))),
+		AverageHeartrate:   int(math.Round(float64(actitvity.AverageHeartrate))),
+		MaxHeartrate:       int(math.Round(float64(actitvity.MaxHeartrate))),
+		AverageWatts:       int(math.Round(float64(actitvity.AverageWatts))),
+		MaxWatts:           int(math.Round(float64(actitvity.MaxWatts))),
+		AverageTemperature: actitvity.AverageTemperature,
+		Polyline:           actitvity.RouteMap.Polyline,
+		Visibility:         actitvity.Visibility,
+	}
+}
+
 // 新增圈數
 func AddLaps(laps []data.Lap) bool {
 	var myRunLaps []model.Lap
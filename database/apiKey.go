@@ -0,0 +1,43 @@
+package database
+
+import (
+	"time"
+
+	"Jimandy-Website-Backend/model"
+)
+
+// 新增個人存取金鑰
+func AddAPIKey(apiKey *model.APIKey) bool {
+	return db.Create(apiKey).Error == nil
+}
+
+// 依 金鑰雜湊 取得 未撤銷的個人存取金鑰
+func GetAPIKeyByHash(keyHash string) *model.APIKey {
+	var apiKey model.APIKey
+	if db.Where("key_hash = ? AND revoked_at IS NULL", keyHash).First(&apiKey).Error != nil {
+		return nil
+	}
+
+	return &apiKey
+}
+
+// 取得帳號未撤銷的個人存取金鑰
+func GetAccountAPIKeys(accountID uint) (apiKeys []model.APIKey) {
+	db.Where("account_id = ? AND revoked_at IS NULL", accountID).Order("created_at").Find(&apiKeys)
+
+	return
+}
+
+// 撤銷個人存取金鑰
+func RevokeAPIKey(accountID uint, id uint, now time.Time) bool {
+	return db.Model(&model.APIKey{}).
+		Where("id = ? AND account_id = ? AND revoked_at IS NULL", id, accountID).
+		Update("revoked_at", now).RowsAffected == 1
+}
+
+// 記錄個人存取金鑰使用時間(每分鐘最多更新一次)
+func TouchAPIKey(id uint, ip string, now time.Time) {
+	db.Model(&model.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-time.Minute)).
+		Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+}
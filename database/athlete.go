@@ -1,6 +1,8 @@
 package database
 
-import "Jimandy-Website-Backend/model"
+import (
+	"Jimandy-Website-Backend/model"
+)
 
 // 依主鍵取得運動員
 func GetAthleteByID(athleteID uint64) (athlete model.Athlete) {
@@ -19,17 +21,33 @@ func AddAthlete(myAthlete *model.Athlete) bool {
 	return db.Save(myAthlete).Error == nil
 }
 
-// 更新運動員權杖
-func UpdateAthleteToken(athleteID uint64, accessToken string, refreshToken string) bool {
-	var athlete model.Athlete
-	db.First(&athlete, athleteID)
-
-	if athlete.ID == 0 {
-		return false
+// 取得運動員與資料庫中換發權杖的原始值(密文)，作為換發後條件更新的版本
+// 先讀取版本再讀取運動員，期間被其他執行個體換發時條件更新會失敗，不會覆寫較新的權杖
+func GetAthleteTokenVersion(athleteID uint64) (athlete model.Athlete, version string, ok bool) {
+	if db.Table("athletes").Select("refresh_token").Where("id = ?", athleteID).Scan(&version).Error != nil {
+		return
 	}
 
-	athlete.AccessToken = accessToken
-	athlete.RefreshToken = refreshToken
+	ok = db.First(&athlete, athleteID).Error == nil
+	return
+}
+
+// 儲存換發後的權杖，只在換發權杖仍為讀取時的版本時寫入
+// 呼叫 Strava 期間不鎖定資料列；回傳 false 表示其他執行個體已先換發
+func SaveAthleteToken(athlete *model.Athlete, version string) bool {
+	result := db.Model(&model.Athlete{ID: athlete.ID}).Where("refresh_token = ?", version).
+		Select("access_token", "refresh_token", "token_expires_at").Updates(athlete)
 
-	return db.Save(&athlete).Error == nil
-}
\ No newline at end of file
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// 更新運動員活動公開設定
+func UpdateAthleteVisibility(athleteID uint64, isPublic bool) bool {
+	return db.Model(&model.Athlete{}).Where("id = ?", athleteID).Update("is_public", isPublic).Error == nil
+}
+
+// 運動員取消授權後清除權杖
+func DeauthorizeAthlete(athleteID uint64) bool {
+	return db.Model(&model.Athlete{}).Where("id = ?", athleteID).
+		Updates(map[string]interface{}{"authorization_code": "", "access_token": "", "refresh_token": ""}).Error == nil
+}
@@ -0,0 +1,28 @@
+package database
+
+import "Jimandy-Website-Backend/model"
+
+// 檢查帳號是否被授權檢視運動員活動
+func IsAthleteViewer(athleteID uint64, accountID uint) bool {
+	var count int64
+	db.Model(&model.AthleteViewer{}).Where("athlete_id = ? AND account_id = ?", athleteID, accountID).Count(&count)
+
+	return count > 0
+}
+
+// 取得運動員授權檢視的帳號
+func GetAthleteViewers(athleteID uint64) (accounts []model.Account) {
+	db.Where("id IN (?)", db.Model(&model.AthleteViewer{}).Select("account_id").Where("athlete_id = ?", athleteID)).Find(&accounts)
+
+	return
+}
+
+// 新增授權檢視的帳號
+func AddAthleteViewer(viewer *model.AthleteViewer) bool {
+	return db.Where(model.AthleteViewer{AthleteID: viewer.AthleteID, AccountID: viewer.AccountID}).FirstOrCreate(viewer).Error == nil
+}
+
+// 移除授權檢視的帳號
+func DeleteAthleteViewer(athleteID uint64, accountID uint) bool {
+	return db.Where("athlete_id = ? AND account_id = ?", athleteID, accountID).Delete(&model.AthleteViewer{}).Error == nil
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"log"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+)
+
+// 定期清除過期資料
+func startCleanupJobs() {
+	// 間隔不為正數時停用，避免迴圈不斷執行
+	if configuration.CleanupInterval <= 0 {
+		log.Println("CLEANUPINTERVAL is not positive, cleanup jobs are disabled")
+		return
+	}
+
+	go func() {
+		for {
+			runCleanupJobs()
+			time.Sleep(configuration.CleanupInterval)
+		}
+	}()
+}
+
+func runCleanupJobs() {
+	now := time.Now()
+
+	if count := PurgeExpiredTokens(now, configuration.CleanupBatchSize); count > 0 {
+		log.Printf("Purged %d expired or revoked tokens", count)
+	}
+
+	if count := PurgeTokenRevocations(now.Add(-configuration.AccessTokenExpireDuration)); count > 0 {
+		log.Printf("Purged %d token revocations", count)
+	}
+
+	if count := PurgeDataExports(now); count > 0 {
+		log.Printf("Purged %d expired data exports", count)
+	}
+
+	if count := PurgeScheduledAccountDeletions(now); count > 0 {
+		log.Printf("Deleted %d accounts after grace period", count)
+	}
+
+	if count := PurgeStravaEvents(now.Add(-configuration.StravaEventRetention)); count > 0 {
+		log.Printf("Purged %d Strava webhook events", count)
+	}
+
+	// 安全事件保留期限，0 表示永久保留
+	if configuration.SecurityEventRetention > 0 {
+		if count := PurgeSecurityEvents(now.Add(-configuration.SecurityEventRetention)); count > 0 {
+			log.Printf("Purged %d security events", count)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"os"
+	"time"
+
+	"Jimandy-Website-Backend/model"
+)
+
+// 新增個人資料匯出
+func AddDataExport(dataExport *model.DataExport) bool {
+	return db.Create(dataExport).Error == nil
+}
+
+// 依 主鍵 取得 帳號的個人資料匯出
+func GetDataExport(accountID uint, id string) *model.DataExport {
+	var dataExport model.DataExport
+	if db.Where("id = ? AND account_id = ?", id, accountID).First(&dataExport).Error != nil {
+		return nil
+	}
+
+	return &dataExport
+}
+
+// 取得帳號的個人資料匯出
+func GetDataExports(accountID uint) (dataExports []model.DataExport) {
+	db.Where("account_id = ?", accountID).Order("created_at DESC").Find(&dataExports)
+
+	return
+}
+
+// 是否有尚未完成的個人資料匯出
+func HasPendingDataExport(accountID uint) bool {
+	var count int64
+	db.Model(&model.DataExport{}).Where("account_id = ? AND status IN ?", accountID, []string{model.ExportPending, model.ExportRunning}).Count(&count)
+
+	return count > 0
+}
+
+// 更新個人資料匯出狀態
+func UpdateDataExport(dataExport *model.DataExport) bool {
+	return db.Save(dataExport).Error == nil
+}
+
+// 刪除超過下載期限的匯出檔，並將中斷超過一小時的匯出標示為失敗
+func PurgeDataExports(now time.Time) (count int64) {
+	var dataExports []model.DataExport
+	db.Where("expires_at < ?", now).Find(&dataExports)
+
+	for _, dataExport := range dataExports {
+		if dataExport.FilePath != "" {
+			_ = os.Remove(dataExport.FilePath)
+		}
+		count += db.Delete(&dataExport).RowsAffected
+	}
+
+	db.Model(&model.DataExport{}).
+		Where("status IN ? AND created_at < ?", []string{model.ExportPending, model.ExportRunning}, now.Add(-time.Hour)).
+		Update("status", model.ExportFailed)
+
+	return
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"Jimandy-Website-Backend/model"
+
+	"gorm.io/gorm"
+)
+
+// 新增變更電子郵件申請，同一帳號未完成的申請作廢
+func AddEmailChange(emailChange *model.EmailChange) bool {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("account_id = ? AND completed_at IS NULL", emailChange.AccountID).Delete(&model.EmailChange{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(emailChange).Error
+	}) == nil
+}
+
+// 以新或原電子郵件的確認權杖確認申請，回傳確認後的申請
+func ConfirmEmailChange(tokenHash string, now time.Time) *model.EmailChange {
+	db.Model(&model.EmailChange{}).
+		Where("old_token_hash = ? AND old_confirmed_at IS NULL AND completed_at IS NULL AND expires_at > ?", tokenHash, now).
+		Update("old_confirmed_at", now)
+	db.Model(&model.EmailChange{}).
+		Where("new_token_hash = ? AND new_confirmed_at IS NULL AND completed_at IS NULL AND expires_at > ?", tokenHash, now).
+		Update("new_confirmed_at", now)
+
+	var emailChange model.EmailChange
+	if db.Where("(old_token_hash = ? OR new_token_hash = ?) AND completed_at IS NULL AND expires_at > ?", tokenHash, tokenHash, now).First(&emailChange).Error != nil {
+		return nil
+	}
+	return &emailChange
+}
+
+// 新舊電子郵件都確認後變更帳號電子郵件
+func CompleteEmailChange(emailChange *model.EmailChange, now time.Time) bool {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.EmailChange{}).
+			Where("id = ? AND old_confirmed_at IS NOT NULL AND new_confirmed_at IS NOT NULL AND completed_at IS NULL", emailChange.ID).
+			Update("completed_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errors.New("email change not confirmed")
+		}
+
+		// 電子郵件已被其他帳號使用時違反唯一限制而失敗
+		result = tx.Model(&model.Account{}).
+			Where("id = ? AND email = ?", emailChange.AccountID, emailChange.OldEmail).
+			Update("email", emailChange.NewEmail)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errors.New("account email changed")
+		}
+
+		return nil
+	}) == nil
+}
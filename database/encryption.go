@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+
+	"gorm.io/gorm/schema"
+)
+
+// 加密欄位的前綴，格式為 enc:v1:<金鑰 ID>:<包裝後的資料金鑰>:<密文>
+const encryptedPrefix = "enc:v1:"
+
+// 舊版由權杖雜湊金鑰衍生的金鑰 ID，只用於解密尚未換用新金鑰的資料
+const legacyEncryptionKeyID = "legacy"
+
+var (
+	encryptionKeys      = map[string][]byte{} // 所有可解密的金鑰，以金鑰 ID 為鍵
+	activeEncryptionKID string                // 目前用於加密的金鑰 ID
+)
+
+func init() {
+	// 模型以 serializer:encrypted 標記需加密的欄位
+	schema.RegisterSerializer("encrypted", encryptedSerializer{})
+}
+
+// 依設定檔載入金鑰加密金鑰
+// ENCRYPTIONKEYS 必須有一把 Active 的金鑰；由權杖雜湊金鑰衍生的舊金鑰只用於解密，待資料轉移後即不再使用
+func SetupEncryptionKeys() error {
+	encryptionKeys = map[string][]byte{}
+	activeEncryptionKID = ""
+
+	if len(configuration.TokenHashKey) > 0 {
+		encryptionKeys[legacyEncryptionKeyID] = utils.DeriveKey(configuration.TokenHashKey, "strava-credentials")
+	}
+
+	for _, config := range configuration.EncryptionKeys {
+		if config.KID == "" || config.KID == legacyEncryptionKeyID || strings.Contains(config.KID, ":") {
+			return fmt.Errorf("invalid encryption key id %q", config.KID)
+		}
+		if _, exists := encryptionKeys[config.KID]; exists {
+			return fmt.Errorf("duplicate encryption key id %q", config.KID)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(config.Key)
+		if err != nil || len(key) != 32 {
+			return fmt.Errorf("encryption key %q must be 32 bytes encoded in base64", config.KID)
+		}
+		encryptionKeys[config.KID] = key
+
+		if config.Active {
+			if activeEncryptionKID != "" {
+				return errors.New("only one encryption key can be active")
+			}
+			activeEncryptionKID = config.KID
+		}
+	}
+
+	if activeEncryptionKID == "" {
+		return errors.New("ENCRYPTIONKEYS must contain an active encryption key")
+	}
+
+	return nil
+}
+
+// 加密字串，空字串不加密；欄位名稱作為附加資料，避免密文被搬到其他欄位使用
+func encryptString(plaintext string, column string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	wrappedKey, ciphertext, err := utils.SealEnvelope(encryptionKeys[activeEncryptionKID], []byte(plaintext), []byte(column))
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + activeEncryptionKID + ":" +
+		base64.RawURLEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+// 解密字串，尚未加密的舊資料直接回傳
+func decryptString(value string, column string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	key, ok := encryptionKeys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("unknown encryption key id %q", parts[0])
+	}
+
+	wrappedKey, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := utils.OpenEnvelope(key, wrappedKey, ciphertext, []byte(column))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// 是否已以目前的金鑰加密(空字串視為不需加密)
+func isEncryptedWithActiveKey(value string) bool {
+	return value == "" || strings.HasPrefix(value, encryptedPrefix+activeEncryptionKID+":")
+}
+
+// 寫入時加密、讀取時解密的字串欄位
+type encryptedSerializer struct{}
+
+func (encryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("unsupported encrypted value type %T", dbValue)
+	}
+
+	plaintext, err := decryptString(value, encryptedColumn(field))
+	if err != nil {
+		return err
+	}
+
+	field.ReflectValueOf(ctx, dst).SetString(plaintext)
+	return nil
+}
+
+func (encryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, _ := fieldValue.(string)
+
+	return encryptString(plaintext, encryptedColumn(field))
+}
+
+// 附加資料使用的資料表與欄位名稱
+func encryptedColumn(field *schema.Field) string {
+	return field.Schema.Table + "." + field.DBName
+}
+
+// 將明碼或以舊金鑰加密的 Strava 憑證改以目前的金鑰加密
+func encryptAthleteSecrets() {
+	var rows []struct {
+		ID                uint64
+		ClientSecret      string
+		AuthorizationCode string
+		AccessToken       string
+		RefreshToken      string
+	}
+	db.Table("athletes").Select("id, client_secret, authorization_code, access_token, refresh_token").Find(&rows)
+
+	for _, row := range rows {
+		if isEncryptedWithActiveKey(row.ClientSecret) && isEncryptedWithActiveKey(row.AuthorizationCode) &&
+			isEncryptedWithActiveKey(row.AccessToken) && isEncryptedWithActiveKey(row.RefreshToken) {
+			continue
+		}
+
+		var athlete model.Athlete
+		if err := db.First(&athlete, row.ID).Error; err != nil {
+			log.Printf("Cannot decrypt credentials of athlete %d: %v", row.ID, err)
+			continue
+		}
+		db.Model(&athlete).Select("client_secret", "authorization_code", "access_token", "refresh_token").Updates(&athlete)
+	}
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/model"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 測試用的 32 bytes 金鑰
+func testEncryptionKey(seed byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune('a'+seed)), 32)))
+}
+
+// 以指定的設定載入金鑰，測試結束後還原
+func useEncryptionKeys(t *testing.T, keys ...configuration.EncryptionKey) error {
+	t.Helper()
+
+	originalTokenHashKey := configuration.TokenHashKey
+	originalKeys := configuration.EncryptionKeys
+	t.Cleanup(func() {
+		configuration.TokenHashKey = originalTokenHashKey
+		configuration.EncryptionKeys = originalKeys
+	})
+
+	configuration.TokenHashKey = []byte("test-token-hash-key-0123456789abcdef")
+	configuration.EncryptionKeys = keys
+	return SetupEncryptionKeys()
+}
+
+func TestSetupEncryptionKeysRequiresActiveKey(t *testing.T) {
+	cases := []struct {
+		name string
+		keys []configuration.EncryptionKey
+	}{
+		{"no keys", nil},
+		{"no active key", []configuration.EncryptionKey{{KID: "a", Key: testEncryptionKey(0)}}},
+		{"two active keys", []configuration.EncryptionKey{
+			{KID: "a", Key: testEncryptionKey(0), Active: true},
+			{KID: "b", Key: testEncryptionKey(1), Active: true},
+		}},
+		{"legacy key id", []configuration.EncryptionKey{{KID: legacyEncryptionKeyID, Key: testEncryptionKey(0), Active: true}}},
+		{"short key", []configuration.EncryptionKey{{KID: "a", Key: base64.StdEncoding.EncodeToString([]byte("short")), Active: true}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := useEncryptionKeys(t, c.keys...); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestEncryptStringRoundTrip(t *testing.T) {
+	if err := useEncryptionKeys(t, configuration.EncryptionKey{KID: "a", Key: testEncryptionKey(0), Active: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := encryptString("secret", "athletes.access_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(ciphertext, encryptedPrefix+"a:") || strings.Contains(ciphertext, "secret") {
+		t.Fatalf("unexpected ciphertext %q", ciphertext)
+	}
+
+	plaintext, err := decryptString(ciphertext, "athletes.access_token")
+	if err != nil || plaintext != "secret" {
+		t.Fatalf("decrypt: %q, %v", plaintext, err)
+	}
+
+	// 密文不可搬到其他欄位使用
+	if _, err := decryptString(ciphertext, "athletes.refresh_token"); err == nil {
+		t.Fatal("ciphertext accepted for another column")
+	}
+
+	// 空字串不加密，尚未加密的舊資料直接回傳
+	if value, _ := encryptString("", "athletes.access_token"); value != "" {
+		t.Fatalf("empty string encrypted to %q", value)
+	}
+	if value, _ := decryptString("plain", "athletes.access_token"); value != "plain" {
+		t.Fatalf("plaintext decrypted to %q", value)
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	if err := useEncryptionKeys(t, configuration.EncryptionKey{KID: "a", Key: testEncryptionKey(0), Active: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 以舊版衍生金鑰加密的資料
+	activeEncryptionKID = legacyEncryptionKeyID
+	legacyValue, err := encryptString("legacy-secret", "athletes.refresh_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeEncryptionKID = "a"
+	oldValue, err := encryptString("old-secret", "athletes.access_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connection, err := gorm.Open(sqlite.Open("file:encryption?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := connection.DB()
+	sqlDB.SetMaxOpenConns(1) // 記憶體資料庫只存在於同一連線
+	t.Cleanup(func() { sqlDB.Close() })
+
+	model.AutoMigrate(connection)
+	connection.Exec("INSERT INTO athletes (id, access_token, refresh_token) VALUES (?, ?, ?)", 1, oldValue, legacyValue)
+
+	// 換用金鑰 b，金鑰 a 與舊版衍生金鑰只用於解密
+	if err := useEncryptionKeys(t,
+		configuration.EncryptionKey{KID: "a", Key: testEncryptionKey(0)},
+		configuration.EncryptionKey{KID: "b", Key: testEncryptionKey(1), Active: true},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if isEncryptedWithActiveKey(oldValue) || isEncryptedWithActiveKey(legacyValue) {
+		t.Fatal("values encrypted with retired keys treated as current")
+	}
+
+	Use(connection)
+
+	var row struct {
+		AccessToken  string
+		RefreshToken string
+	}
+	connection.Table("athletes").Select("access_token, refresh_token").Where("id = ?", 1).Scan(&row)
+	if !strings.HasPrefix(row.AccessToken, encryptedPrefix+"b:") || !strings.HasPrefix(row.RefreshToken, encryptedPrefix+"b:") {
+		t.Fatalf("credentials not re-encrypted: %+v", row)
+	}
+
+	athlete := GetAthleteByID(1)
+	if athlete.AccessToken != "old-secret" || athlete.RefreshToken != "legacy-secret" {
+		t.Fatalf("unexpected credentials: %q, %q", athlete.AccessToken, athlete.RefreshToken)
+	}
+
+	// 移除舊金鑰後仍可讀取已轉移的資料
+	if err := useEncryptionKeys(t, configuration.EncryptionKey{KID: "b", Key: testEncryptionKey(1), Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	if athlete := GetAthleteByID(1); athlete.AccessToken != "old-secret" {
+		t.Fatalf("unexpected access token after removing old keys: %q", athlete.AccessToken)
+	}
+}
@@ -0,0 +1,15 @@
+package database
+
+import "Jimandy-Website-Backend/model"
+
+// 依 身分提供者與使用者識別 取得 外部身分
+func GetIdentity(provider string, subject string) (identity model.Identity) {
+	db.Where("provider = ? AND subject = ?", provider, subject).First(&identity)
+
+	return
+}
+
+// 新增外部身分
+func AddIdentity(identity *model.Identity) bool {
+	return db.Create(identity).Error == nil
+}
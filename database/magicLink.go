@@ -0,0 +1,28 @@
+package database
+
+import (
+	"time"
+
+	"Jimandy-Website-Backend/model"
+)
+
+// 新增免密碼登入連結
+func AddMagicLink(magicLink *model.MagicLink) bool {
+	return db.Create(magicLink).Error == nil
+}
+
+// 使用免密碼登入連結，連結只能使用一次
+func UseMagicLink(tokenHash string, now time.Time) *model.MagicLink {
+	result := db.Model(&model.MagicLink{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+		Update("used_at", now)
+	if result.Error != nil || result.RowsAffected != 1 {
+		return nil
+	}
+
+	var magicLink model.MagicLink
+	if db.Where("token_hash = ?", tokenHash).First(&magicLink).Error != nil {
+		return nil
+	}
+	return &magicLink
+}
@@ -1,9 +1,9 @@
 package database
 
 import (
-	"fmt"
 	"Jimandy-Website-Backend/configuration"
 	"Jimandy-Website-Backend/model"
+	"fmt"
 	"time"
 
 	"gorm.io/driver/postgres"
@@ -38,5 +38,21 @@ func Open() {
 	// 連線最長可複用的時間
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
+	migrate()
+
+	startRevocationListener() // 接收權杖撤銷通知
+	startCleanupJobs()        // 定期清除過期資料
+}
+
+// 使用指定的資料庫連線並轉移資料表結構，不啟動背景工作(測試使用)
+func Use(connection *gorm.DB) {
+	db = connection
+	migrate()
+}
+
+// 轉移資料表結構與既有資料
+func migrate() {
 	model.AutoMigrate(db)
+	migrateTokenHashes()    // 權杖改為只保存雜湊
+	encryptAthleteSecrets() // 加密 Strava 憑證並換用目前的金鑰
 }
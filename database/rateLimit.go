@@ -0,0 +1,60 @@
+package database
+
+import (
+	"time"
+
+	"Jimandy-Website-Backend/model"
+
+	"gorm.io/gorm"
+)
+
+// 限流紀錄最長保留時間
+const rateLimitRetention = 24 * time.Hour
+
+// 以 Postgres 儲存限流狀態，多個執行個體共用計數
+type RateLimitStore struct{}
+
+func NewRateLimitStore() RateLimitStore {
+	go func() {
+		for range time.Tick(10 * time.Minute) {
+			db.Where("hit_at < ?", time.Now().Add(-rateLimitRetention)).Delete(&model.RateLimitHit{})
+		}
+	}()
+	return RateLimitStore{}
+}
+
+func (RateLimitStore) Take(key string, now time.Time, window time.Duration, allow func(hits []time.Time) bool) (allowed bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 以交易層級的 advisory lock 讓各執行個體對同一鍵值的請求依序執行
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", key).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("key = ? AND hit_at < ?", key, now.Add(-window)).Delete(&model.RateLimitHit{}).Error; err != nil {
+			return err
+		}
+
+		var hits []time.Time
+		if err := tx.Model(&model.RateLimitHit{}).Where("key = ?", key).Order("hit_at").Pluck("hit_at", &hits).Error; err != nil {
+			return err
+		}
+		if allowed = allow(hits); !allowed {
+			return nil
+		}
+		return tx.Create(&model.RateLimitHit{Key: key, HitAt: now}).Error
+	})
+	if err != nil {
+		allowed = false
+	}
+
+	return
+}
+
+func (RateLimitStore) Hits(key string, since time.Time) (hits []time.Time, err error) {
+	err = db.Model(&model.RateLimitHit{}).Where("key = ? AND hit_at >= ?", key, since).Order("hit_at").Pluck("hit_at", &hits).Error
+
+	return
+}
+
+func (RateLimitStore) Reset(key string) error {
+	return db.Where("key = ?", key).Delete(&model.RateLimitHit{}).Error
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/patrickmn/go-cache"
+)
+
+// 撤銷與權限異動通知頻道
+const revocationChannel = "token_revocation"
+
+// 權限異動通知的鍵值前綴
+const permissionChangePrefix = "perm:"
+
+// 收到權限異動通知時呼叫，由 api 清除本機的權限快取
+var permissionChangeHandler func(accountID uint)
+
+// 已撤銷的工作階段與帳號，值為撤銷時間(Unix 毫秒)
+// 只需保留到撤銷前簽發的 access token 全部逾時為止
+var revocations = cache.New(time.Hour, 10*time.Minute)
+
+// 撤銷鍵值
+func sessionRevocationKey(sessionID string) string {
+	return "sid:" + sessionID
+}
+
+func accountRevocationKey(accountID uint) string {
+	return fmt.Sprintf("acct:%d", accountID)
+}
+
+// 記錄撤銷時間，保留較晚的撤銷時間
+func addRevocation(key string, revokedAt int64) {
+	if cachedData, found := revocations.Get(key); found && cachedData.(int64) >= revokedAt {
+		return
+	}
+	revocations.Set(key, revokedAt, configuration.AccessTokenExpireDuration)
+}
+
+// 記錄撤銷並通知其他執行個體
+func publishRevocation(key string, revokedAt time.Time) {
+	addRevocation(key, revokedAt.UnixMilli())
+	db.Exec("SELECT pg_notify(?, ?)", revocationChannel, fmt.Sprintf("%s|%d", key, revokedAt.UnixMilli()))
+}
+
+// 撤銷帳號在此時間前簽發的所有權杖，並保存撤銷紀錄供重新啟動後載入
+func publishAccountRevocation(accountID uint, revokedAt time.Time) {
+	db.Create(&model.TokenRevocation{AccountID: accountID, RevokedAt: revokedAt})
+	publishRevocation(accountRevocationKey(accountID), revokedAt)
+}
+
+// 刪除已超過 access token 效期的帳號撤銷紀錄
+func PurgeTokenRevocations(before time.Time) int64 {
+	return db.Where("revoked_at < ?", before).Delete(&model.TokenRevocation{}).RowsAffected
+}
+
+// 設定收到權限異動通知時的處理
+func OnPermissionChange(handler func(accountID uint)) {
+	permissionChangeHandler = handler
+}
+
+// 通知所有執行個體(包含自己)帳號權限已異動
+func PublishPermissionChange(accountID uint) {
+	handlePermissionChange(accountID)
+	db.Exec("SELECT pg_notify(?, ?)", revocationChannel, fmt.Sprintf("%s%d|%d", permissionChangePrefix, accountID, time.Now().Unix()))
+}
+
+func handlePermissionChange(accountID uint) {
+	if permissionChangeHandler != nil {
+		permissionChangeHandler(accountID)
+	}
+}
+
+// 檢查 access token 是否已被撤銷(不查詢資料庫)，issuedAt 為簽發時間(Unix 毫秒)
+// 以毫秒比較，撤銷後同一秒內重新登入取得的權杖不會被誤判為已撤銷
+func IsTokenRevoked(sessionID string, accountID uint, issuedAt int64) bool {
+	for _, key := range []string{sessionRevocationKey(sessionID), accountRevocationKey(accountID)} {
+		if cachedData, found := revocations.Get(key); found && issuedAt <= cachedData.(int64) {
+			return true
+		}
+	}
+	return false
+}
+
+// 載入最近撤銷的工作階段與帳號，並開始接收其他執行個體的撤銷通知
+func startRevocationListener() {
+	since := time.Now().Add(-configuration.AccessTokenExpireDuration)
+
+	var sessions []model.Session
+	db.Select("id, revoked_at").Where("revoked_at > ?", since).Find(&sessions)
+	for _, session := range sessions {
+		addRevocation(sessionRevocationKey(session.ID), session.RevokedAt.UnixMilli())
+	}
+
+	var accountRevocations []model.TokenRevocation
+	db.Where("revoked_at > ?", since).Find(&accountRevocations)
+	for _, revocation := range accountRevocations {
+		addRevocation(accountRevocationKey(revocation.AccountID), revocation.RevokedAt.UnixMilli())
+	}
+
+	go listenRevocations()
+}
+
+// 接收撤銷通知，斷線後重新連線
+func listenRevocations() {
+	for {
+		if err := waitRevocations(); err != nil {
+			log.Println("Revocation listener:", err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func waitRevocations() error {
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, configuration.ListenConnectionstring)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	if _, err = conn.Exec(ctx, "LISTEN "+revocationChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		key, revokedAt, found := strings.Cut(notification.Payload, "|")
+		if !found {
+			continue
+		}
+
+		// 權限異動通知
+		if accountID, isPermissionChange := strings.CutPrefix(key, permissionChangePrefix); isPermissionChange {
+			if id, err := strconv.ParseUint(accountID, 10, 64); err == nil {
+				handlePermissionChange(uint(id))
+			}
+			continue
+		}
+
+		if unix, err := strconv.ParseInt(revokedAt, 10, 64); err == nil {
+			addRevocation(key, unix)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package database
+
+import "Jimandy-Website-Backend/model"
+
+// 取得帳號擁有的角色(預設角色、指派角色，管理員另加管理員角色)
+func GetAccountRoles(accountID uint) (roles []model.Role) {
+	db.Preload("Permissions").
+		Where("is_default = ?", true).
+		Or("id IN (?)", db.Table("account_roles").Select("role_id").Where("account_id = ?", accountID)).
+		Or("name = ? AND EXISTS (?)", model.RoleAdmin, db.Model(&model.Account{}).Select("1").Where("id = ? AND is_admin = 1", accountID)).
+		Find(&roles)
+
+	return
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"time"
+
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/model"
+
+	"gorm.io/gorm"
+)
+
+// 新增安全事件
+func AddSecurityEvent(event *model.SecurityEvent) bool {
+	return db.Create(event).Error == nil
+}
+
+// 依條件分頁取得安全事件(新到舊)，並回傳總筆數
+func GetSecurityEvents(filter data.SecurityEventFilter, offset int, limit int) (events []model.SecurityEvent, total int64) {
+	query := db.Model(&model.SecurityEvent{})
+	if filter.AccountID != 0 {
+		query = query.Where("account_id = ?", filter.AccountID)
+	}
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	query = query.Session(&gorm.Session{}) // 總筆數與分頁查詢共用條件
+	query.Count(&total)
+	query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&events)
+
+	return
+}
+
+// 刪除超過保留期限的安全事件，回傳刪除筆數
+func PurgeSecurityEvents(before time.Time) int64 {
+	return db.Where("created_at < ?", before).Delete(&model.SecurityEvent{}).RowsAffected
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"time"
+
+	"Jimandy-Website-Backend/model"
+
+	"gorm.io/gorm"
+)
+
+// 新增工作階段與第一組 token
+func AddSession(session *model.Session, token *model.Token) bool {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	}) == nil
+}
+
+// 依 主鍵 取得 工作階段
+func GetSessionByID(sessionID string) *model.Session {
+	var session model.Session
+	if db.Where("id = ?", sessionID).First(&session).Error != nil {
+		return nil
+	}
+	return &session
+}
+
+// 取得帳號有效的工作階段
+func GetAccountSessions(accountID uint, now time.Time) (sessions []model.Session) {
+	db.Where("account_id = ? AND revoked_at IS NULL AND expires_at > ?", accountID, now).Order("last_seen_at DESC").Find(&sessions)
+
+	return
+}
+
+// 取得帳號所有工作階段(含已登出)
+func GetAllAccountSessions(accountID uint) (sessions []model.Session) {
+	db.Where("account_id = ?", accountID).Order("first_seen_at").Find(&sessions)
+
+	return
+}
+
+// 更新工作階段顯示名稱
+func RenameSession(sessionID string, name string) bool {
+	return db.Model(&model.Session{}).Where("id = ?", sessionID).Update("name", name).Error == nil
+}
+
+// 撤銷工作階段與其所有 token
+func RevokeSession(sessionID string, now time.Time) bool {
+	if sessionID == "" {
+		return false
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Session{}).Where("id = ? AND revoked_at IS NULL", sessionID).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Token{}).Where("session_id = ?", sessionID).Update("is_revoked", true).Error
+	})
+	if err != nil {
+		return false
+	}
+
+	publishRevocation(sessionRevocationKey(sessionID), now)
+
+	return true
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"Jimandy-Website-Backend/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 新增 Strava 推播事件，重複的事件回傳 false
+func AddStravaEvent(event *model.StravaEvent) bool {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(event).RowsAffected == 1
+}
+
+// 依主鍵取得 Strava 推播事件
+func GetStravaEventByID(id uint) (event model.StravaEvent) {
+	db.First(&event, id)
+
+	return
+}
+
+// 認領時間，資料庫只保存到微秒，比對認領時間時需相同精度
+func claimTime() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// 認領一筆尚未處理的 Strava 推播事件，已由其他工作認領且未逾時則回傳 false
+func ClaimStravaEvent(event *model.StravaEvent, timeout time.Duration) bool {
+	now := claimTime()
+
+	if db.Model(&model.StravaEvent{}).
+		Where("id = ? AND processed_at IS NULL AND (processing_at IS NULL OR processing_at < ?)", event.ID, now.Add(-timeout)).
+		Update("processing_at", now).RowsAffected != 1 {
+		return false
+	}
+
+	event.ProcessingAt = &now
+	return true
+}
+
+// 認領尚未處理完成且未超過重試次數的 Strava 推播事件，同一事件同時只會被一個執行個體取得
+func ClaimPendingStravaEvents(maxAttempts int, timeout time.Duration, limit int) (events []model.StravaEvent) {
+	now := claimTime()
+
+	pending := db.Model(&model.StravaEvent{}).Select("id").
+		Where("processed_at IS NULL AND attempts < ? AND (processing_at IS NULL OR processing_at < ?)", maxAttempts, now.Add(-timeout)).
+		Order("id").Limit(limit).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+
+	db.Model(&events).Clauses(clause.Returning{}).Where("id IN (?)", pending).Update("processing_at", now)
+	slices.SortFunc(events, func(a, b model.StravaEvent) int { return cmp.Compare(a.ID, b.ID) })
+
+	return
+}
+
+// 記錄 Strava 推播事件處理結果，認領已逾時並由其他工作重新認領時不記錄，回傳 false
+// 處理成功時，同一物件在開始處理前收到的同類事件已包含在取得的最新狀態中，一併完成
+func FinishStravaEvent(event *model.StravaEvent, processErr error) bool {
+	if event.ProcessingAt == nil {
+		return false
+	}
+
+	updates := map[string]interface{}{
+		"attempts":      gorm.Expr("attempts + 1"),
+		"error":         "",
+		"processing_at": nil,
+	}
+	var processedAt *time.Time
+	if processErr != nil {
+		updates["error"] = processErr.Error()
+	} else {
+		now := time.Now()
+		processedAt = &now
+		updates["processed_at"] = now
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.StravaEvent{}).Where("id = ? AND processing_at = ?", event.ID, *event.ProcessingAt).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if processedAt == nil {
+			return nil
+		}
+		return tx.Model(&model.StravaEvent{}).
+			Where("owner_id = ? AND object_id = ? AND aspect_type = ? AND id <> ? AND processed_at IS NULL AND received_at <= ?",
+				event.OwnerID, event.ObjectID, event.AspectType, event.ID, *event.ProcessingAt).
+			Updates(map[string]interface{}{"processed_at": *processedAt, "processing_at": nil}).Error
+	}) == nil
+}
+
+// 刪除早於指定時間的 Strava 推播事件
+func PurgeStravaEvents(before time.Time) int64 {
+	return db.Where("received_at < ?", before).Delete(&model.StravaEvent{}).RowsAffected
+}
@@ -1,16 +1,79 @@
 package database
 
-import "Jimandy-Website-Backend/model"
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// 權杖雜湊金鑰最短長度
+const minTokenHashKeyLength = 32
+
+// 由權杖雜湊金鑰衍生、只用於計算權杖雜湊的子金鑰
+var tokenHashKey []byte
+
+// 檢查並載入權杖雜湊金鑰
+// 未設定 TOKENHASHKEY 時使用 KEY，兩者皆以衍生的子金鑰計算雜湊，不與權杖簽章共用金鑰
+func SetupTokenHashKey() error {
+	if len(configuration.TokenHashKey) == 0 {
+		return errors.New("TOKENHASHKEY or KEY must be set")
+	}
+	if len(configuration.TokenHashKey) < minTokenHashKeyLength {
+		return fmt.Errorf("TOKENHASHKEY must be at least %d bytes", minTokenHashKeyLength)
+	}
+
+	tokenHashKey = utils.DeriveKey(configuration.TokenHashKey, "token-hash")
+
+	return nil
+}
+
+// 權杖雜湊(含登入連結、變更電子郵件等一次性權杖)，資料庫只保存以金鑰計算的雜湊，外洩時無法取得有效權杖
+func HashToken(token string) string {
+	mac := hmac.New(sha256.New, tokenHashKey)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
 
 // 新增 token
 func SaveTokens(token *model.Token) bool {
 	return db.Save(token).Error == nil
 }
 
+// 換發 token，舊 token 標記為已換發，同時間只有一個請求能換發成功
+// 並更新工作階段最後使用資訊
+func RotateTokens(oldToken *model.Token, newToken *model.Token, session *model.Session) bool {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Token{}).
+			Where("id = ? AND rotated_at IS NULL AND is_revoked = ?", oldToken.ID, false).
+			Update("rotated_at", newToken.CreatedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Model(session).Select("last_ip", "user_agent", "last_seen_at", "expires_at").Updates(session).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(newToken).Error
+	}) == nil
+}
+
 // 依 access token 取得 token
 func GetTokenByAccessToken(accessToken string) *model.Token {
 	var token model.Token
-	if db.Where("access_token = ?", accessToken).First(&token).Error != nil {
+	if db.Where("access_token_hash = ?", HashToken(accessToken)).First(&token).Error != nil {
 		return nil
 	}
 	return &token
@@ -19,7 +82,7 @@ func GetTokenByAccessToken(accessToken string) *model.Token {
 // 依 refresh token 取得 token
 func GetTokenByRefreshToken(refreshToken string) *model.Token {
 	var token model.Token
-	if db.Where("refresh_token = ?", refreshToken).First(&token).Error != nil {
+	if db.Where("refresh_token_hash = ?", HashToken(refreshToken)).First(&token).Error != nil {
 		return nil
 	}
 	return &token
@@ -28,16 +91,65 @@ func GetTokenByRefreshToken(refreshToken string) *model.Token {
 // 取得用戶的所有  token
 func GetUserTokens(accountID uint) []model.Token {
 	var tokens []model.Token
-	db.Where("account_id = ? AND is_revoked = ?", accountID, false).Find(&tokens)
+	db.Where("account_id = ? AND is_revoked = ? AND rotated_at IS NULL", accountID, false).Find(&tokens)
 	return tokens
 }
 
+// 分批刪除已失效的 token，回傳刪除筆數
+// refresh token 過期，或已撤銷且 access token 已過期的 token 不再有用途
+// 已換發但未過期的 token 保留，用於偵測 refresh token 重複使用
+func PurgeExpiredTokens(now time.Time, batchSize int) (total int64) {
+	for {
+		expired := db.Model(&model.Token{}).Select("id").
+			Where("refresh_expires_at < ? OR (is_revoked = ? AND expires_at < ?)", now, true, now).
+			Limit(batchSize)
+
+		count := db.Where("id IN (?)", expired).Delete(&model.Token{}).RowsAffected
+		total += count
+		// 沒有可刪除的 token(或刪除失敗)時結束，避免批次大小不合法時無限迴圈
+		if count == 0 || count < int64(batchSize) {
+			return
+		}
+	}
+}
+
 // 撤銷用戶的所有 token
 func RevokeAllUserTokens(accountID uint) bool {
-	// 撤銷所有 token
+	now := time.Now()
+
+	// 撤銷所有工作階段與 token
+	if err := db.Model(&model.Session{}).Where("account_id = ? AND revoked_at IS NULL", accountID).Update("revoked_at", now).Error; err != nil {
+		return false
+	}
 	if err := db.Model(&model.Token{}).Where("account_id = ?", accountID).Update("is_revoked", true).Error; err != nil {
 		return false
 	}
 
+	publishAccountRevocation(accountID, now)
+
 	return true
 }
+
+// 舊版權杖以明碼保存，改為保存雜湊後移除明碼欄位
+func migrateTokenHashes() {
+	if !db.Migrator().HasColumn(&model.Token{}, "access_token") {
+		return
+	}
+
+	var tokens []struct {
+		ID           uint
+		AccessToken  string
+		RefreshToken string
+	}
+	db.Model(&model.Token{}).Select("id, access_token, refresh_token").Where("access_token_hash IS NULL OR access_token_hash = ''").Find(&tokens)
+
+	for _, token := range tokens {
+		db.Model(&model.Token{}).Where("id = ?", token.ID).Updates(map[string]interface{}{
+			"access_token_hash":  HashToken(token.AccessToken),
+			"refresh_token_hash": HashToken(token.RefreshToken),
+		})
+	}
+
+	_ = db.Migrator().DropColumn(&model.Token{}, "access_token")
+	_ = db.Migrator().DropColumn(&model.Token{}, "refresh_token")
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"time"
+
+	"Jimandy-Website-Backend/model"
+
+	"gorm.io/gorm"
+)
+
+// 設定尚未啟用的兩步驟驗證金鑰
+func SetPendingTOTPSecret(accountID uint, secret string) bool {
+	return db.Model(&model.Account{}).
+		Where("id = ? AND totp_enabled = ?", accountID, false).
+		Update("totp_secret", secret).RowsAffected == 1
+}
+
+// 啟用兩步驟驗證並產生新的備用碼
+func EnableTOTP(accountID uint, step int64, recoveryCodes []model.RecoveryCode) bool {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Account{}).Where("id = ?", accountID).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+
+		return replaceRecoveryCodes(tx, accountID, recoveryCodes)
+	}) == nil
+}
+
+// 停用兩步驟驗證並刪除備用碼
+func DisableTOTP(accountID uint) bool {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Account{}).Where("id = ?", accountID).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("account_id = ?", accountID).Delete(&model.RecoveryCode{}).Error
+	}) == nil
+}
+
+// 記錄已使用的驗證碼週期，同一週期(含更早)的驗證碼不可重複使用
+func UseTOTPStep(accountID uint, step int64) bool {
+	return db.Model(&model.Account{}).
+		Where("id = ? AND totp_last_step < ?", accountID, step).
+		Update("totp_last_step", step).RowsAffected == 1
+}
+
+// 重新產生備用碼
+func ReplaceRecoveryCodes(accountID uint, recoveryCodes []model.RecoveryCode) bool {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, accountID, recoveryCodes)
+	}) == nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, accountID uint, recoveryCodes []model.RecoveryCode) error {
+	if err := tx.Where("account_id = ?", accountID).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&recoveryCodes).Error
+}
+
+// 使用備用碼(原子更新，避免同一組備用碼被使用兩次)
+func UseRecoveryCode(accountID uint, codeHash string, now time.Time) bool {
+	return db.Model(&model.RecoveryCode{}).
+		Where("account_id = ? AND code_hash = ? AND used_at IS NULL", accountID, codeHash).
+		Update("used_at", now).RowsAffected == 1
+}
+
+// 取得剩餘可用的備用碼數量
+func CountRecoveryCodes(accountID uint) (count int64) {
+	db.Model(&model.RecoveryCode{}).Where("account_id = ? AND used_at IS NULL", accountID).Count(&count)
+
+	return
+}
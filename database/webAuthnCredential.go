@@ -0,0 +1,50 @@
+package database
+
+import (
+	"time"
+
+	"Jimandy-Website-Backend/model"
+)
+
+// 取得帳號的所有通行金鑰
+func GetWebAuthnCredentials(accountID uint) (credentials []model.WebAuthnCredential) {
+	db.Where("account_id = ?", accountID).Order("created_at").Find(&credentials)
+
+	return
+}
+
+// 依 憑證ID 取得 通行金鑰
+func GetWebAuthnCredentialByCredentialID(credentialID []byte) *model.WebAuthnCredential {
+	var credential model.WebAuthnCredential
+	if db.Where("credential_id = ?", credentialID).First(&credential).Error != nil {
+		return nil
+	}
+
+	return &credential
+}
+
+// 新增通行金鑰
+func AddWebAuthnCredential(credential *model.WebAuthnCredential) bool {
+	return db.Create(credential).Error == nil
+}
+
+// 更新通行金鑰的簽章計數與使用時間
+func UpdateWebAuthnCredentialUsage(id uint, signCount uint32, backupState bool, now time.Time) bool {
+	return db.Model(&model.WebAuthnCredential{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"sign_count":   signCount,
+		"backup_state": backupState,
+		"last_used_at": now,
+	}).Error == nil
+}
+
+// 修改通行金鑰顯示名稱
+func RenameWebAuthnCredential(accountID uint, id uint, name string) bool {
+	return db.Model(&model.WebAuthnCredential{}).
+		Where("id = ? AND account_id = ?", id, accountID).
+		Update("name", name).RowsAffected == 1
+}
+
+// 刪除通行金鑰
+func DeleteWebAuthnCredential(accountID uint, id uint) bool {
+	return db.Where("id = ? AND account_id = ?", id, accountID).Delete(&model.WebAuthnCredential{}).RowsAffected == 1
+}
@@ -0,0 +1,221 @@
+package helper
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+	"Jimandy-Website-Backend/utils"
+)
+
+// 同時產生的匯出數量上限
+var exportSlots = make(chan struct{}, 2)
+
+// 在背景產生個人資料匯出
+func StartDataExport(dataExport model.DataExport) {
+	go func() {
+		exportSlots <- struct{}{}
+		defer func() { <-exportSlots }()
+
+		dataExport.Status = model.ExportRunning
+		database.UpdateDataExport(&dataExport)
+
+		filePath := filepath.Join(configuration.ExportPath, dataExport.ID+".zip")
+		size, err := buildDataExport(dataExport.AccountID, filePath)
+		if err != nil {
+			log.Printf("Data export %s failed: %v", dataExport.ID, err)
+			_ = os.Remove(filePath)
+			dataExport.Status = model.ExportFailed
+			database.UpdateDataExport(&dataExport)
+			return
+		}
+
+		now := utils.GetCurrentTime()
+		expiresAt := now.Add(configuration.ExportExpireDuration)
+		dataExport.Status = model.ExportCompleted
+		dataExport.FilePath = filePath
+		dataExport.Size = size
+		dataExport.CompletedAt = &now
+		dataExport.ExpiresAt = &expiresAt
+		database.UpdateDataExport(&dataExport)
+	}()
+}
+
+// 產生個人資料壓縮檔，回傳檔案大小
+func buildDataExport(accountID uint, filePath string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o700); err != nil {
+		return 0, err
+	}
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	if err := writeDataExport(archive, accountID); err != nil {
+		return 0, err
+	}
+	if err := archive.Close(); err != nil {
+		return 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// 寫入帳號、工作階段、運動員、活動與圈數
+func writeDataExport(archive *zip.Writer, accountID uint) error {
+	account := database.GetAccountByID(uint64(accountID))
+	if err := writeJSONFile(archive, "account.json", account); err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "sessions.json", database.GetAllAccountSessions(accountID)); err != nil {
+		return err
+	}
+
+	athlete := database.GetAthleteByAccountID(accountID)
+	if athlete.ID == 0 {
+		return nil
+	}
+
+	// 運動員不含 Strava 憑證
+	if err := writeJSONFile(archive, "athlete.json", athlete.Public()); err != nil {
+		return err
+	}
+
+	activities := database.GetActivitiesByAthleteID(athlete.ID)
+	laps := database.GetLapsByAthleteID(athlete.ID)
+
+	if err := writeJSONFile(archive, "activities.json", activities); err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "laps.json", laps); err != nil {
+		return err
+	}
+	if err := writeActivitiesCSV(archive, activities); err != nil {
+		return err
+	}
+	if err := writeLapsCSV(archive, laps); err != nil {
+		return err
+	}
+
+	// 以路線產生 GPX 軌跡
+	for _, activity := range activities {
+		if activity.Polyline == "" {
+			continue
+		}
+		writer, err := archive.Create(fmt.Sprintf("gpx/%d.gpx", activity.ID))
+		if err != nil {
+			return err
+		}
+		if err := utils.WriteGPX(writer, activity.Name, activity.Date, utils.DecodePolyline(activity.Polyline)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 寫入 JSON 檔
+func writeJSONFile(archive *zip.Writer, name string, value interface{}) error {
+	writer, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// 試算表會將開頭為這些字元的儲存格視為公式
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// 文字欄位開頭為公式字元時加上單引號，避免以試算表開啟時執行使用者輸入的公式
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// 寫入 CSV 檔
+func writeCSVFile(archive *zip.Writer, name string, rows [][]string) error {
+	writer, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	return csv.NewWriter(writer).WriteAll(rows)
+}
+
+func writeActivitiesCSV(archive *zip.Writer, activities []model.Activity) error {
+	rows := [][]string{{
+		"id", "name", "sport_type", "date", "elapsed_time", "moving_time", "distance", "total_elevation_gain",
+		"average_speed", "max_speed", "average_cadence", "average_heartrate", "max_heartrate",
+		"average_watts", "max_watts", "average_temperature", "visibility",
+	}}
+	for _, activity := range activities {
+		rows = append(rows, []string{
+			strconv.FormatUint(activity.ID, 10),
+			csvText(activity.Name),
+			csvText(activity.SportType),
+			activity.Date.UTC().Format(time.RFC3339),
+			strconv.Itoa(activity.ElapsedTime),
+			strconv.Itoa(activity.MovingTime),
+			strconv.Itoa(activity.Distance),
+			strconv.Itoa(activity.TotalElevationGain),
+			strconv.FormatFloat(float64(activity.AverageSpeed), 'f', -1, 32),
+			strconv.FormatFloat(float64(activity.MaxSpeed), 'f', -1, 32),
+			strconv.Itoa(activity.AverageCadence),
+			strconv.Itoa(activity.AverageHeartrate),
+			strconv.Itoa(activity.MaxHeartrate),
+			strconv.Itoa(activity.AverageWatts),
+			strconv.Itoa(activity.MaxWatts),
+			strconv.Itoa(activity.AverageTemperature),
+			csvText(activity.Visibility),
+		})
+	}
+
+	return writeCSVFile(archive, "activities.csv", rows)
+}
+
+func writeLapsCSV(archive *zip.Writer, laps []model.Lap) error {
+	rows := [][]string{{
+		"id", "activity_id", "elapsed_time", "moving_time", "distance", "average_speed", "max_speed",
+		"average_cadence", "average_heartrate", "max_heartrate", "average_watts", "total_elevation_gain",
+	}}
+	for _, lap := range laps {
+		rows = append(rows, []string{
+			strconv.FormatUint(lap.ID, 10),
+			strconv.FormatUint(lap.ActivityID, 10),
+			strconv.Itoa(lap.ElapsedTime),
+			strconv.Itoa(lap.MovingTime),
+			strconv.Itoa(lap.Distance),
+			strconv.FormatFloat(float64(lap.AverageSpeed), 'f', -1, 32),
+			strconv.FormatFloat(float64(lap.MaxSpeed), 'f', -1, 32),
+			strconv.Itoa(lap.AverageCadence),
+			strconv.Itoa(lap.AverageHeartrate),
+			strconv.Itoa(lap.MaxHeartrate),
+			strconv.Itoa(lap.AverageWatts),
+			strconv.Itoa(lap.TotalElevationGain),
+		})
+	}
+
+	return writeCSVFile(archive, "laps.csv", rows)
+}
@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"Jimandy-Website-Backend/model"
+)
+
+func TestWriteActivitiesCSVEscapesFormulas(t *testing.T) {
+	names := []string{"=HYPERLINK(\"http://example.com\")", "+1", "-1", "@SUM(A1)", "\tTab", "Morning Run", ""}
+	activities := make([]model.Activity, len(names))
+	for i, name := range names {
+		activities[i] = model.Activity{ID: uint64(i + 1), Name: name, AverageTemperature: -3}
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	if err := writeActivitiesCSV(archive, activities); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := reader.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"'=HYPERLINK(\"http://example.com\")", "'+1", "'-1", "'@SUM(A1)", "'\tTab", "Morning Run", ""}
+	for i, name := range want {
+		if rows[i+1][1] != name {
+			t.Errorf("row %d: name %q, want %q", i+1, rows[i+1][1], name)
+		}
+		// 數值欄位不加引號
+		if rows[i+1][15] != "-3" {
+			t.Errorf("row %d: temperature %q", i+1, rows[i+1][15])
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package helper
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/data"
+)
+
+// 外部身分提供者介面
+type IdentityProvider interface {
+	// 產生導向提供者的授權網址
+	AuthCodeURL(state string, nonce string) string
+	// 以授權碼交換並驗證使用者身分
+	Exchange(code string, nonce string) (data.ExternalIdentity, error)
+}
+
+// 已設定的外部身分提供者，以名稱為鍵
+var IdentityProviders = map[string]IdentityProvider{}
+
+// 依設定檔建立外部身分提供者
+func SetupIdentityProviders() {
+	IdentityProviders = map[string]IdentityProvider{}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	for _, provider := range configuration.IdentityProviders {
+		switch provider.Type {
+		case "oidc":
+			IdentityProviders[provider.Name] = NewOIDCProvider(provider, client)
+		case "strava":
+			IdentityProviders[provider.Name] = NewStravaIdentityProvider(provider, client)
+		default:
+			log.Printf("Unknown identity provider type %q for %q", provider.Type, provider.Name)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"strings"
+
+	"Jimandy-Website-Backend/configuration"
+)
+
+// 寄信介面，測試時可替換為本機假物件
+type MailSender interface {
+	Send(to string, subject string, body string) error
+}
+
+// 目前使用的寄信實作
+var Mailer MailSender = LogMailSender{}
+
+// 依設定檔建立寄信實作，未設定郵件伺服器時只寫入日誌
+func SetupMailer() {
+	if configuration.SMTPHost == "" {
+		log.Println("SMTPHOST not set, mails will only be logged")
+		Mailer = LogMailSender{}
+		return
+	}
+
+	Mailer = SMTPMailSender{
+		Host:     configuration.SMTPHost,
+		Port:     configuration.SMTPPort,
+		Username: configuration.SMTPUsername,
+		Password: configuration.SMTPPassword,
+		From:     configuration.MailFrom,
+	}
+}
+
+// 透過 SMTP 寄信
+type SMTPMailSender struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (sender SMTPMailSender) Send(to string, subject string, body string) error {
+	var auth smtp.Auth
+	if sender.Username != "" {
+		auth = smtp.PlainAuth("", sender.Username, sender.Password, sender.Host)
+	}
+
+	message := strings.Join([]string{
+		"From: " + sender.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(fmt.Sprintf("%s:%d", sender.Host, sender.Port), auth, sender.From, []string{to}, []byte(message))
+}
+
+// 只將信件寫入日誌，供開發環境使用
+type LogMailSender struct{}
+
+func (LogMailSender) Send(to string, subject string, body string) error {
+	log.Printf("Mail to %s: %s\n%s", to, subject, body)
+	return nil
+}
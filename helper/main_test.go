@@ -0,0 +1,61 @@
+package helper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/database"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 以記憶體資料庫執行 helper 測試
+func TestMain(m *testing.M) {
+	os.Setenv("KEY", "test-signing-key-0123456789abcdef")
+	configuration.ReadConfiguration()
+	configuration.EncryptionKeys = []configuration.EncryptionKey{
+		{KID: "test", Key: "dGVzdC1lbmNyeXB0aW9uLWtleS0wMTIzNDU2Nzg5YWI=", Active: true},
+	}
+
+	if err := database.SetupTokenHashKey(); err != nil {
+		panic(err)
+	}
+	if err := database.SetupEncryptionKeys(); err != nil {
+		panic(err)
+	}
+
+	connection, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		panic(err)
+	}
+	sqlDB, _ := connection.DB()
+	sqlDB.SetMaxOpenConns(1) // 記憶體資料庫只存在於同一連線
+	database.Use(connection)
+
+	os.Exit(m.Run())
+}
+
+// 將 HTTP 請求直接交給處理函式
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (transport handlerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	transport.handler.ServeHTTP(recorder, request)
+	return recorder.Result(), nil
+}
+
+// 以處理函式取代 Strava API，測試結束後還原
+func useFakeStrava(t *testing.T, handler http.Handler) {
+	original := stravaClient
+	stravaClient = &http.Client{Transport: handlerTransport{handler: handler}}
+	t.Cleanup(func() { stravaClient = original })
+}
@@ -0,0 +1,216 @@
+package helper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/data"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// OpenID Connect 探索文件
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OpenID Connect 權杖回應
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+}
+
+// OpenID Connect 身分提供者
+type OIDCProvider struct {
+	name   string
+	config configuration.IdentityProvider
+	client *http.Client
+
+	mutex     sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{}
+	keysAt    time.Time
+}
+
+func NewOIDCProvider(config configuration.IdentityProvider, client *http.Client) *OIDCProvider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &OIDCProvider{name: config.Name, config: config, client: client}
+}
+
+// 取得探索文件
+func (provider *OIDCProvider) getDiscovery() (*oidcDiscovery, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.discovery != nil {
+		return provider.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := provider.getJSON(strings.TrimSuffix(provider.config.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != provider.config.Issuer {
+		return nil, fmt.Errorf("issuer mismatch: %s", discovery.Issuer)
+	}
+
+	provider.discovery = &discovery
+	return provider.discovery, nil
+}
+
+// 依 kid 取得驗證金鑰，找不到時重新下載 JWKS (每分鐘最多一次)
+func (provider *OIDCProvider) getKey(kid string) (interface{}, error) {
+	discovery, err := provider.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if key, ok := provider.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(provider.keysAt) < time.Minute {
+		return nil, errors.New("unknown key id " + kid)
+	}
+
+	var keySet JSONWebKeySet
+	if err := provider.getJSON(discovery.JWKSURI, &keySet); err != nil {
+		return nil, err
+	}
+
+	provider.keys = map[string]interface{}{}
+	provider.keysAt = time.Now()
+	for _, webKey := range keySet.Keys {
+		if webKey.Use != "" && webKey.Use != "sig" {
+			continue
+		}
+		if publicKey, err := webKey.PublicKey(); err == nil {
+			provider.keys[webKey.KeyID] = publicKey
+		}
+	}
+
+	if key, ok := provider.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown key id " + kid)
+}
+
+func (provider *OIDCProvider) getJSON(url string, result interface{}) error {
+	response, err := provider.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, response.Status)
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+func (provider *OIDCProvider) AuthCodeURL(state string, nonce string) string {
+	discovery, err := provider.getDiscovery()
+	if err != nil {
+		return ""
+	}
+
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {provider.config.ClientID},
+		"redirect_uri":  {provider.config.RedirectURL},
+		"scope":         {strings.Join(provider.config.Scopes, " ")},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	return discovery.AuthorizationEndpoint + "?" + query.Encode()
+}
+
+func (provider *OIDCProvider) Exchange(code string, nonce string) (identity data.ExternalIdentity, err error) {
+	discovery, err := provider.getDiscovery()
+	if err != nil {
+		return identity, err
+	}
+
+	response, err := provider.client.PostForm(discovery.TokenEndpoint, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {provider.config.RedirectURL},
+		"client_id":     {provider.config.ClientID},
+		"client_secret": {provider.config.ClientSecret},
+	})
+	if err != nil {
+		return identity, err
+	}
+	defer response.Body.Close()
+
+	var tokens oidcTokenResponse
+	if err = json.NewDecoder(response.Body).Decode(&tokens); err != nil {
+		return identity, err
+	}
+	if response.StatusCode != http.StatusOK || tokens.IDToken == "" {
+		return identity, fmt.Errorf("token exchange failed: %s %s", response.Status, tokens.Error)
+	}
+
+	return provider.verifyIDToken(tokens.IDToken, nonce)
+}
+
+// 驗證 ID Token 的簽章、發行者、對象、逾時與 nonce
+func (provider *OIDCProvider) verifyIDToken(idToken string, nonce string) (identity data.ExternalIdentity, err error) {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}))
+
+	claims := jwt.MapClaims{}
+	_, err = parser.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return provider.getKey(kid)
+	})
+	if err != nil {
+		return identity, err
+	}
+
+	if !claims.VerifyIssuer(provider.config.Issuer, true) {
+		return identity, errors.New("invalid issuer")
+	}
+	if !claims.VerifyAudience(provider.config.ClientID, true) {
+		return identity, errors.New("invalid audience")
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return identity, errors.New("id token expired")
+	}
+	if claimNonce, _ := claims["nonce"].(string); claimNonce == "" || claimNonce != nonce {
+		return identity, errors.New("invalid nonce")
+	}
+
+	identity.Provider = provider.name
+	identity.Type = provider.config.Type
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+
+	// 部分提供者以字串回傳 email_verified
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	if identity.Subject == "" {
+		return identity, errors.New("missing subject")
+	}
+	return identity, nil
+}
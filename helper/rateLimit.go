@@ -0,0 +1,163 @@
+package helper
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+)
+
+// 登入失敗紀錄保留時間，超過後重新計算鎖定
+const loginFailureWindow = 24 * time.Hour
+
+// 限流狀態儲存介面，以滑動視窗記錄每次呼叫的時間
+type RateLimitStore interface {
+	// 在單一不可分割的操作中清除超過視窗的紀錄、以 allow 判斷視窗內的呼叫時間(由舊到新排序)，
+	// allow 回傳 true 時記錄本次呼叫；同一鍵值的同時呼叫必須依序執行
+	Take(key string, now time.Time, window time.Duration, allow func(hits []time.Time) bool) (bool, error)
+	// 取得指定時間之後的呼叫時間，由舊到新排序
+	Hits(key string, since time.Time) ([]time.Time, error)
+	// 清除紀錄
+	Reset(key string) error
+}
+
+// 限流器
+type RateLimiter struct {
+	Store RateLimitStore
+}
+
+// 目前使用的限流器
+var Limiter = &RateLimiter{Store: NewMemoryRateLimitStore()}
+
+// 檢查並記錄一次呼叫，超過次數時回傳需等待的時間
+func (limiter *RateLimiter) Allow(key string, limit int, window time.Duration) (bool, time.Duration) {
+	now := time.Now()
+
+	var wait time.Duration
+	allowed, err := limiter.Store.Take(key, now, window, func(hits []time.Time) bool {
+		if len(hits) >= limit {
+			// 等到視窗內最早的呼叫過期後才有額度
+			wait = hits[len(hits)-limit].Add(window).Sub(now)
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		// 儲存失敗時一律拒絕請求
+		log.Println("rate limit store:", err)
+		return false, window
+	}
+
+	return allowed, wait
+}
+
+// 開始一次需驗證的嘗試：未鎖定時先記錄一次失敗，回傳 0；鎖定時回傳剩餘鎖定時間
+// 檢查與記錄為單一操作，同時送出的嘗試不會繞過鎖定；驗證成功後須呼叫 ResetFailures
+func (limiter *RateLimiter) BeginAttempt(key string) time.Duration {
+	now := time.Now()
+
+	var wait time.Duration
+	_, err := limiter.Store.Take(key, now, loginFailureWindow, func(failures []time.Time) bool {
+		wait = lockedFor(failures, now)
+		return wait == 0
+	})
+	if err != nil {
+		// 儲存失敗時一律視為鎖定
+		log.Println("rate limit store:", err)
+		return configuration.LoginLockoutDuration
+	}
+
+	return wait
+}
+
+// 依失敗紀錄計算剩餘鎖定時間，連續失敗達門檻後鎖定，之後每次失敗鎖定時間加倍
+func lockedFor(failures []time.Time, now time.Time) time.Duration {
+	if len(failures) < configuration.LoginLockoutThreshold {
+		return 0
+	}
+
+	duration := configuration.LoginLockoutDuration
+	for i := configuration.LoginLockoutThreshold; i < len(failures) && duration < configuration.LoginLockoutMaxDuration; i++ {
+		duration *= 2
+	}
+	if duration > configuration.LoginLockoutMaxDuration {
+		duration = configuration.LoginLockoutMaxDuration
+	}
+
+	lockedUntil := failures[len(failures)-1].Add(duration)
+	if now.Before(lockedUntil) {
+		return lockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// 清除失敗紀錄
+func (limiter *RateLimiter) ResetFailures(key string) {
+	_ = limiter.Store.Reset(key)
+}
+
+// 記憶體限流狀態，只適用單一執行個體
+type MemoryRateLimitStore struct {
+	mutex sync.Mutex
+	hits  map[string][]time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	store := &MemoryRateLimitStore{hits: map[string][]time.Time{}}
+	go store.cleanup()
+	return store
+}
+
+func (store *MemoryRateLimitStore) Take(key string, now time.Time, window time.Duration, allow func(hits []time.Time) bool) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	hits := trimHits(store.hits[key], now.Add(-window))
+	allowed := allow(hits)
+	if allowed {
+		hits = append(hits, now)
+	}
+	store.hits[key] = hits
+	return allowed, nil
+}
+
+func (store *MemoryRateLimitStore) Hits(key string, since time.Time) ([]time.Time, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return append([]time.Time(nil), trimHits(store.hits[key], since)...), nil
+}
+
+func (store *MemoryRateLimitStore) Reset(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.hits, key)
+	return nil
+}
+
+// 定期清除過期的紀錄
+func (store *MemoryRateLimitStore) cleanup() {
+	for range time.Tick(10 * time.Minute) {
+		since := time.Now().Add(-loginFailureWindow)
+
+		store.mutex.Lock()
+		for key, hits := range store.hits {
+			if hits = trimHits(hits, since); len(hits) == 0 {
+				delete(store.hits, key)
+			} else {
+				store.hits[key] = hits
+			}
+		}
+		store.mutex.Unlock()
+	}
+}
+
+// 移除指定時間之前的紀錄
+func trimHits(hits []time.Time, since time.Time) []time.Time {
+	for len(hits) > 0 && hits[0].Before(since) {
+		hits = hits[1:]
+	}
+	return hits
+}
@@ -0,0 +1,108 @@
+package helper
+
+import (
+	"testing"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+)
+
+// 設定登入鎖定門檻與時間，測試結束後還原
+func useLoginLockout(t *testing.T, threshold int, duration time.Duration, maxDuration time.Duration) {
+	originalThreshold, originalDuration, originalMax := configuration.LoginLockoutThreshold, configuration.LoginLockoutDuration, configuration.LoginLockoutMaxDuration
+	configuration.LoginLockoutThreshold, configuration.LoginLockoutDuration, configuration.LoginLockoutMaxDuration = threshold, duration, maxDuration
+	t.Cleanup(func() {
+		configuration.LoginLockoutThreshold, configuration.LoginLockoutDuration, configuration.LoginLockoutMaxDuration = originalThreshold, originalDuration, originalMax
+	})
+}
+
+func TestRateLimiterWindow(t *testing.T) {
+	limiter := &RateLimiter{Store: NewMemoryRateLimitStore()}
+	window := 100 * time.Millisecond
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.Allow("ip:1", 2, window); !allowed {
+			t.Fatalf("call %d rejected", i+1)
+		}
+	}
+
+	allowed, wait := limiter.Allow("ip:1", 2, window)
+	if allowed || wait <= 0 || wait > window {
+		t.Fatalf("over limit: allowed %v, wait %v", allowed, wait)
+	}
+
+	// 其他鍵值不受影響
+	if allowed, _ := limiter.Allow("ip:2", 2, window); !allowed {
+		t.Fatal("other key rejected")
+	}
+
+	// 視窗內最早的呼叫過期後恢復額度
+	time.Sleep(wait + 10*time.Millisecond)
+	if allowed, _ := limiter.Allow("ip:1", 2, window); !allowed {
+		t.Fatal("rejected after the window passed")
+	}
+}
+
+func TestRateLimiterLockout(t *testing.T) {
+	useLoginLockout(t, 3, time.Minute, time.Hour)
+	limiter := &RateLimiter{Store: NewMemoryRateLimitStore()}
+
+	// 未達門檻前的嘗試皆可進行，每次嘗試先記錄一次失敗
+	for i := 0; i < 3; i++ {
+		if wait := limiter.BeginAttempt("login:a"); wait != 0 {
+			t.Fatalf("attempt %d locked for %v", i+1, wait)
+		}
+	}
+
+	wait := limiter.BeginAttempt("login:a")
+	if wait <= 0 || wait > time.Minute {
+		t.Fatalf("not locked after threshold: wait %v", wait)
+	}
+
+	// 鎖定期間的嘗試不會延長鎖定
+	if again := limiter.BeginAttempt("login:a"); again <= 0 || again > wait {
+		t.Fatalf("locked attempt: wait %v, before %v", again, wait)
+	}
+
+	// 驗證成功後清除失敗紀錄
+	limiter.ResetFailures("login:a")
+	if wait := limiter.BeginAttempt("login:a"); wait != 0 {
+		t.Fatalf("locked after reset: wait %v", wait)
+	}
+}
+
+func TestLockedForDoublesUpToMax(t *testing.T) {
+	useLoginLockout(t, 3, time.Minute, 5*time.Minute)
+	now := time.Now()
+
+	failures := func(count int) []time.Time {
+		times := make([]time.Time, count)
+		for i := range times {
+			times[i] = now
+		}
+		return times
+	}
+
+	tests := []struct {
+		failures int
+		locked   time.Duration
+	}{
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{6, 5 * time.Minute},
+		{10, 5 * time.Minute},
+	}
+
+	for _, test := range tests {
+		if locked := lockedFor(failures(test.failures), now); locked != test.locked {
+			t.Errorf("%d failures: locked %v, want %v", test.failures, locked, test.locked)
+		}
+	}
+
+	// 最後一次失敗後經過鎖定時間即解除
+	if locked := lockedFor(failures(3), now.Add(time.Minute)); locked != 0 {
+		t.Fatalf("still locked after duration: %v", locked)
+	}
+}
@@ -0,0 +1,229 @@
+package helper
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/utils"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// 舊版 HS256 金鑰的金鑰 ID，直接以 KEY 簽章，只用於驗證
+const legacyKeyID = "legacy"
+
+// 由 KEY 衍生的 HS256 簽章金鑰的金鑰 ID
+const derivedKeyID = "hs256"
+
+// HS256 金鑰最短長度
+const minHMACKeyLength = 32
+
+// 權杖簽章金鑰
+type signingKey struct {
+	KID     string
+	Method  jwt.SigningMethod
+	Private interface{} // 簽章用金鑰，只有驗證用的金鑰為 nil
+	Public  interface{} // 驗證用金鑰
+}
+
+var (
+	signingKeys      = map[string]*signingKey{} // 所有可驗證的金鑰，以金鑰 ID 為鍵
+	activeSigningKey *signingKey                // 目前用於簽章的金鑰
+)
+
+// 依設定檔載入權杖簽章金鑰
+// 未設定 JWTSIGNINGKEYS 時以 KEY 衍生的子金鑰作為 HS256 金鑰；KEY 仍可保留用於驗證舊權杖
+func SetupSigningKeys() error {
+	signingKeys = map[string]*signingKey{}
+	activeSigningKey = nil
+
+	if len(configuration.JWTKey) > 0 {
+		if len(configuration.JWTKey) < minHMACKeyLength {
+			return fmt.Errorf("KEY must be at least %d bytes", minHMACKeyLength)
+		}
+		signingKeys[legacyKeyID] = &signingKey{KID: legacyKeyID, Method: jwt.SigningMethodHS256, Public: configuration.JWTKey}
+
+		derivedKey := utils.DeriveKey(configuration.JWTKey, "jwt-signing")
+		signingKeys[derivedKeyID] = &signingKey{KID: derivedKeyID, Method: jwt.SigningMethodHS256, Private: derivedKey, Public: derivedKey}
+	}
+
+	for _, config := range configuration.JWTSigningKeys {
+		key, err := parseSigningKey(config)
+		if err != nil {
+			return fmt.Errorf("signing key %q: %w", config.KID, err)
+		}
+		if _, exists := signingKeys[key.KID]; exists {
+			return fmt.Errorf("duplicate signing key id %q", key.KID)
+		}
+		signingKeys[key.KID] = key
+
+		if config.Active {
+			if activeSigningKey != nil {
+				return errors.New("only one signing key can be active")
+			}
+			if key.Private == nil {
+				return fmt.Errorf("active signing key %q has no private key", key.KID)
+			}
+			activeSigningKey = key
+		}
+	}
+
+	if activeSigningKey == nil {
+		if len(configuration.JWTSigningKeys) > 0 {
+			return errors.New("no active signing key in JWTSIGNINGKEYS")
+		}
+		if signingKeys[derivedKeyID] == nil {
+			return errors.New("KEY or JWTSIGNINGKEYS must be set")
+		}
+		activeSigningKey = signingKeys[derivedKeyID]
+	}
+
+	return nil
+}
+
+// 解析 PEM 格式的簽章金鑰
+func parseSigningKey(config configuration.SigningKey) (*signingKey, error) {
+	if config.KID == "" || config.KID == legacyKeyID || config.KID == derivedKeyID {
+		return nil, errors.New("invalid key id")
+	}
+
+	key := &signingKey{KID: config.KID}
+	switch config.Algorithm {
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+	case "EdDSA":
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("unsupported algorithm " + config.Algorithm)
+	}
+
+	if config.PrivateKey != "" {
+		block, _ := pem.Decode([]byte(config.PrivateKey))
+		if block == nil {
+			return nil, errors.New("invalid private key PEM")
+		}
+
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			// RSA 金鑰也可能為 PKCS#1 格式
+			if privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				return nil, err
+			}
+		}
+		key.Private = privateKey
+		key.Public = privateKey.(crypto.Signer).Public()
+	} else if config.PublicKey != "" {
+		block, _ := pem.Decode([]byte(config.PublicKey))
+		if block == nil {
+			return nil, errors.New("invalid public key PEM")
+		}
+
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.Public = publicKey
+	} else {
+		return nil, errors.New("PrivateKey or PublicKey is required")
+	}
+
+	// 檢查金鑰類型與演算法相符
+	switch key.Public.(type) {
+	case *rsa.PublicKey:
+		if key.Method != jwt.SigningMethodRS256 {
+			return nil, errors.New("RSA key requires RS256")
+		}
+	case ed25519.PublicKey:
+		if key.Method != jwt.SigningMethodEdDSA {
+			return nil, errors.New("Ed25519 key requires EdDSA")
+		}
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+
+	return key, nil
+}
+
+// 以目前的簽章金鑰簽署權杖
+func SignToken(claims jwt.MapClaims) (string, error) {
+	if activeSigningKey == nil {
+		return "", errors.New("signing key not configured")
+	}
+
+	token := jwt.NewWithClaims(activeSigningKey.Method, claims)
+	token.Header["kid"] = activeSigningKey.KID
+
+	return token.SignedString(activeSigningKey.Private)
+}
+
+// 依權杖的 kid 取得驗證金鑰，並檢查演算法、發行者與對象
+func TokenKeyFunc(token *jwt.Token) (interface{}, error) {
+	// 舊版權杖沒有 kid，使用 HS256 金鑰驗證
+	kid, hasKeyID := token.Header["kid"].(string)
+	if !hasKeyID {
+		kid = legacyKeyID
+	}
+
+	key, ok := signingKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unexpected jwt key id=%v", token.Header["kid"])
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected jwt signing method=%v", token.Header["alg"])
+	}
+
+	// 舊版權杖沒有發行者與對象
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && hasKeyID {
+		if !claims.VerifyIssuer(configuration.JWTIssuer, true) || !claims.VerifyAudience(configuration.JWTAudience, true) {
+			return nil, errors.New("invalid issuer or audience")
+		}
+	}
+
+	return key.Public, nil
+}
+
+// 取得公開的驗證金鑰(不含 HS256 金鑰)
+func PublicJSONWebKeySet() JSONWebKeySet {
+	keySet := JSONWebKeySet{Keys: []JSONWebKey{}}
+
+	kids := make([]string, 0, len(signingKeys))
+	for kid := range signingKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	for _, kid := range kids {
+		key := signingKeys[kid]
+		switch publicKey := key.Public.(type) {
+		case *rsa.PublicKey:
+			keySet.Keys = append(keySet.Keys, JSONWebKey{
+				KeyType:   "RSA",
+				KeyID:     key.KID,
+				Use:       "sig",
+				Algorithm: key.Method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keySet.Keys = append(keySet.Keys, JSONWebKey{
+				KeyType:   "OKP",
+				KeyID:     key.KID,
+				Use:       "sig",
+				Algorithm: key.Method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+
+	return keySet
+}
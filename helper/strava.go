@@ -5,19 +5,63 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"Jimandy-Website-Backend/configuration"
 	"Jimandy-Website-Backend/data"
 	"Jimandy-Website-Backend/database"
 	"Jimandy-Website-Backend/model"
 )
 
 var urlMap = map[string]string{
+	"authorize":                    "https://www.strava.com/oauth/authorize",
 	"Authorization":                "https://www.strava.com/oauth/token?grant_type=authorization_code",
 	"getLoggedInAthleteActivities": "https://www.strava.com/api/v3/athlete/activities?per_page=200",
 	"getLapsByActivityId":          "https://www.strava.com/api/v3/activities/{id}/laps",
+	"getActivityById":              "https://www.strava.com/api/v3/activities/{id}",
+	"pushSubscriptions":            "https://www.strava.com/api/v3/push_subscriptions",
 	"refreshToken":                 "https://www.strava.com/api/v3/oauth/token?", // client_id={client_id}&client_secret={client_secret}&grant_type=refresh_token&refresh_token={refresh_token}
+	"getLoggedInAthlete":           "https://www.strava.com/api/v3/athlete",
+}
+
+// 呼叫 Strava API 的用戶端
+var stravaClient = &http.Client{Timeout: 30 * time.Second}
+
+// Strava API 回應的錯誤狀態
+type StravaError struct {
+	StatusCode int
+	Body       string
+}
+
+func (err *StravaError) Error() string {
+	return fmt.Sprintf("strava responded %d: %s", err.StatusCode, err.Body)
+}
+
+// 是否為 Strava 回應的指定錯誤狀態
+func isStravaStatus(err error, statusCodes ...int) bool {
+	var stravaError *StravaError
+	return errors.As(err, &stravaError) && slices.Contains(statusCodes, stravaError.StatusCode)
+}
+
+// 在配額內發出 Strava 請求，並依回應更新配額
+func doStravaRequest(request *http.Request) (*http.Response, error) {
+	if err := stravaRateLimit.wait(); err != nil {
+		return nil, err
+	}
+
+	resp, err := stravaClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	stravaRateLimit.update(resp)
+
+	return resp, nil
 }
 
 func FetchStravaApi[T any](method string, url string, accessToken string, result T) (T, error) {
@@ -29,8 +73,7 @@ func FetchStravaApi[T any](method string, url string, accessToken string, result
 
 	request.Header.Add("Authorization", "Bearer "+accessToken)
 
-	client := &http.Client{}
-	resp, err := client.Do(request)
+	resp, err := doStravaRequest(request)
 	if err != nil {
 		fmt.Println("Error making request:", err)
 		return result, err
@@ -43,6 +86,10 @@ func FetchStravaApi[T any](method string, url string, accessToken string, result
 		return result, err
 	}
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		return result, &StravaError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
 	err = json.Unmarshal(body, &result)
 	if err != nil {
 		fmt.Println("Error unmarshalling JSON:", err)
@@ -52,40 +99,47 @@ func FetchStravaApi[T any](method string, url string, accessToken string, result
 	return result, nil
 }
 
-func AuthorizeStrava(myAthlete model.Athlete) bool {
-	authorizationUrl := fmt.Sprintf("%s&client_id=%d&client_secret=%s&code=%s", urlMap["Authorization"], myAthlete.ClientID, myAthlete.ClientSecret, myAthlete.AuthorizationCode)
-
-	tokens, err := FetchStravaApi("POST", authorizationUrl, "", data.Token{})
-	if err != nil || tokens.Athlete.AthleteID == 0 {
-		return false
+// 產生連結運動員的 Strava 授權網址
+func StravaAuthorizeURL(state string) string {
+	query := url.Values{
+		"response_type":   {"code"},
+		"client_id":       {strconv.FormatUint(uint64(configuration.StravaClientID), 10)},
+		"redirect_uri":    {configuration.StravaRedirectURL},
+		"approval_prompt": {"auto"},
+		"scope":           {strings.Join(configuration.StravaScopes, ",")},
+		"state":           {state},
 	}
-
-	myAthlete.ID = tokens.Athlete.AthleteID
-	myAthlete.AccessToken = tokens.AccessToken
-	myAthlete.RefreshToken = tokens.RefreshToken
-
-	return database.AddAthlete(&myAthlete)
+	return urlMap["authorize"] + "?" + query.Encode()
 }
 
-func FetchNewAccessToken(myAthlete model.Athlete) string {
-	refreshTokenUrl := fmt.Sprintf("%sclient_id=%d&client_secret=%s&grant_type=refresh_token&refresh_token=%s", urlMap["refreshToken"], myAthlete.ClientID, myAthlete.ClientSecret, myAthlete.RefreshToken)
+// 以應用程式的用戶端憑證交換授權碼
+func ExchangeStravaCode(code string) (data.Token, error) {
+	exchangeUrl := urlMap["Authorization"] + "&" + url.Values{
+		"client_id":     {strconv.FormatUint(uint64(configuration.StravaClientID), 10)},
+		"client_secret": {configuration.StravaClientSecret},
+		"code":          {code},
+	}.Encode()
 
-	tokens, err := FetchStravaApi("POST", refreshTokenUrl, "", data.Token{})
+	tokens, err := FetchStravaApi("POST", exchangeUrl, "", data.Token{})
 	if err != nil {
-		return ""
+		return tokens, err
+	}
+	if tokens.Athlete.AthleteID == 0 || tokens.RefreshToken == "" {
+		return tokens, errors.New("strava authorization failed")
 	}
 
-	_ = database.UpdateAthleteToken(myAthlete.ID, tokens.AccessToken, tokens.RefreshToken)
-
-	return tokens.AccessToken
+	return tokens, nil
 }
 
 func FetchStravaActivities(myAthlete model.Athlete) error {
 	url := urlMap["getLoggedInAthleteActivities"]
 
+	// 若 有尚未取得可見度的活動 則 重新爬取所有活動以補上可見度
+	resync := database.HasActivitiesWithoutVisibility(myAthlete.ID)
+
 	lastActivityDate := database.GetLatestActivityDate(uint64(myAthlete.ID)) // 取得運動員最近一次的跑步活動日期
 	// 若 有最後一次跑步活動 則 爬取這之後的活動
-	if lastActivityDate.Unix() > 0 {
+	if !resync && lastActivityDate.Unix() > 0 {
 		url += fmt.Sprintf("&after=%d", lastActivityDate.Unix())
 	}
 
@@ -93,19 +147,9 @@ func FetchStravaActivities(myAthlete model.Athlete) error {
 
 	page := 1
 	for {
-		result, err := FetchStravaApi("GET", fmt.Sprintf("%s&page=%d", url, page), myAthlete.AccessToken, []data.Activities{})
+		result, err := fetchStravaApiAsAthlete(&myAthlete, "GET", fmt.Sprintf("%s&page=%d", url, page), []data.Activities{})
 		if err != nil {
-			accessToken := FetchNewAccessToken(myAthlete)
-
-			if accessToken == "" {
-				return errors.New("Invalid AccessToken")
-			}
-
-			myAthlete.AccessToken = accessToken
-			result, err = FetchStravaApi("GET", url, myAthlete.AccessToken, []data.Activities{})
-			if err != nil {
-				return errors.New("Error fetching strava api")
-			}
+			return err
 		}
 
 		if len(result) == 0 {
@@ -119,24 +163,39 @@ func FetchStravaActivities(myAthlete model.Athlete) error {
 		return errors.New("Error Saving activities")
 	}
 
+	// 重新爬取後仍無可見度的活動已不在 Strava 上，視為不公開
+	if resync && !database.SetMissingActivityVisibility(myAthlete.ID, model.VisibilityOnlyMe) {
+		return errors.New("Error Saving activities")
+	}
+
 	return nil
 }
 
+// 補上可見度欄位新增前爬取的活動的可見度，每位運動員重新爬取一次
+// 補上前這些活動一律不公開
+func BackfillActivityVisibility() {
+	for _, athleteID := range database.GetAthleteIDsWithoutActivityVisibility() {
+		myAthlete := database.GetAthleteByID(athleteID)
+
+		// 已取消授權的運動員無法再取得活動，視為不公開
+		if myAthlete.ID == 0 || myAthlete.RefreshToken == "" {
+			database.SetMissingActivityVisibility(athleteID, model.VisibilityOnlyMe)
+			continue
+		}
+
+		if err := FetchStravaActivities(myAthlete); err != nil {
+			log.Printf("Backfill activity visibility of athlete %d failed: %v", athleteID, err)
+		}
+	}
+}
+
 // 取得活動圈數
 func FetchStravaLaps(myAthlete model.Athlete, activityID string) error {
 	url := strings.Replace(urlMap["getLapsByActivityId"], "{id}", activityID, 1)
 
-	result, err := FetchStravaApi("GET", url, myAthlete.AccessToken, []data.Lap{})
+	result, err := fetchStravaApiAsAthlete(&myAthlete, "GET", url, []data.Lap{})
 	if err != nil {
-		accessToken := FetchNewAccessToken(myAthlete)
-		if accessToken == "" {
-			return errors.New("Invalid AccessToken")
-		}
-
-		result, err = FetchStravaApi("GET", url, accessToken, []data.Lap{})
-		if err != nil {
-			return errors.New("Error fetching strava api")
-		}
+		return err
 	}
 
 	if !database.AddLaps(result) {
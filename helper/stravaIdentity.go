@@ -0,0 +1,62 @@
+package helper
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/data"
+)
+
+// 以 Strava OAuth2 登入的身分提供者
+// Strava 不提供電子郵件，僅能對應到已連結的運動員
+type StravaIdentityProvider struct {
+	name   string
+	config configuration.IdentityProvider
+	client *http.Client
+}
+
+func NewStravaIdentityProvider(config configuration.IdentityProvider, client *http.Client) *StravaIdentityProvider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"read"}
+	}
+	return &StravaIdentityProvider{name: config.Name, config: config, client: client}
+}
+
+func (provider *StravaIdentityProvider) AuthCodeURL(state string, nonce string) string {
+	query := url.Values{
+		"response_type":   {"code"},
+		"client_id":       {provider.config.ClientID},
+		"redirect_uri":    {provider.config.RedirectURL},
+		"approval_prompt": {"auto"},
+		"scope":           {strings.Join(provider.config.Scopes, ",")},
+		"state":           {state},
+	}
+	return urlMap["authorize"] + "?" + query.Encode()
+}
+
+func (provider *StravaIdentityProvider) Exchange(code string, nonce string) (identity data.ExternalIdentity, err error) {
+	exchangeUrl := urlMap["Authorization"] + "&" + url.Values{
+		"client_id":     {provider.config.ClientID},
+		"client_secret": {provider.config.ClientSecret},
+		"code":          {code},
+	}.Encode()
+
+	tokens, err := FetchStravaApi("POST", exchangeUrl, "", data.Token{})
+	if err != nil {
+		return identity, err
+	}
+	if tokens.Athlete.AthleteID == 0 {
+		return identity, errors.New("strava authorization failed")
+	}
+
+	identity.Provider = provider.name
+	identity.Type = provider.config.Type
+	identity.Subject = strconv.FormatUint(tokens.Athlete.AthleteID, 10)
+	identity.Name = strings.TrimSpace(tokens.Athlete.FirstName + " " + tokens.Athlete.LastName)
+
+	return identity, nil
+}
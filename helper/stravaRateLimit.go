@@ -0,0 +1,171 @@
+package helper
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+)
+
+// 超過 Strava 配額且等待時間超過上限
+var ErrStravaRateLimited = errors.New("strava rate limit exceeded")
+
+// Strava 短期配額視窗(每 15 分鐘，自整點起算)
+const stravaShortWindow = 15 * time.Minute
+
+// Strava API 配額使用狀況，依回應的 X-RateLimit-Limit 與 X-RateLimit-Usage 更新
+// 呼叫前先預留一次配額，避免同時發出的請求超過配額
+type stravaRateLimiter struct {
+	mutex        sync.Mutex
+	shortLimit   int       // 15 分鐘配額
+	shortUsage   int       // 15 分鐘已使用次數
+	dailyLimit   int       // 每日配額
+	dailyUsage   int       // 每日已使用次數
+	shortWindow  time.Time // 15 分鐘用量所屬的視窗
+	dailyWindow  time.Time // 每日用量所屬的視窗
+	blockedUntil time.Time // 收到 429 後暫停呼叫到此時間
+	updatedAt    time.Time // 最後一次收到 Strava 配額資訊的時間
+}
+
+// Strava API 配額使用狀況
+type StravaRateLimitUsage struct {
+	ShortLimit   int
+	ShortUsage   int
+	ShortResetAt time.Time
+	DailyLimit   int
+	DailyUsage   int
+	DailyResetAt time.Time
+	BlockedUntil *time.Time
+	UpdatedAt    *time.Time
+}
+
+// 所有 Strava API 呼叫共用的配額
+var stravaRateLimit = &stravaRateLimiter{shortLimit: 200, dailyLimit: 2000}
+
+// 取得目前的 Strava API 配額使用狀況
+func GetStravaRateLimitUsage() StravaRateLimitUsage {
+	stravaRateLimit.mutex.Lock()
+	defer stravaRateLimit.mutex.Unlock()
+
+	now := time.Now().UTC()
+	stravaRateLimit.rollWindows(now)
+
+	usage := StravaRateLimitUsage{
+		ShortLimit:   stravaRateLimit.shortLimit,
+		ShortUsage:   stravaRateLimit.shortUsage,
+		ShortResetAt: stravaRateLimit.shortWindow.Add(stravaShortWindow),
+		DailyLimit:   stravaRateLimit.dailyLimit,
+		DailyUsage:   stravaRateLimit.dailyUsage,
+		DailyResetAt: stravaRateLimit.dailyWindow.AddDate(0, 0, 1),
+	}
+	if now.Before(stravaRateLimit.blockedUntil) {
+		blockedUntil := stravaRateLimit.blockedUntil
+		usage.BlockedUntil = &blockedUntil
+	}
+	if !stravaRateLimit.updatedAt.IsZero() {
+		updatedAt := stravaRateLimit.updatedAt
+		usage.UpdatedAt = &updatedAt
+	}
+
+	return usage
+}
+
+// 等待到配額可用並預留一次呼叫，需等待超過 STRAVARATELIMITMAXWAIT 時回傳錯誤
+func (limiter *stravaRateLimiter) wait() error {
+	for {
+		limiter.mutex.Lock()
+		now := time.Now().UTC()
+		limiter.rollWindows(now)
+
+		resumeAt := limiter.resumeAt(now)
+		if resumeAt.IsZero() {
+			limiter.shortUsage++
+			limiter.dailyUsage++
+			limiter.mutex.Unlock()
+			return nil
+		}
+		limiter.mutex.Unlock()
+
+		delay := resumeAt.Sub(now)
+		if delay > configuration.StravaRateLimitMaxWait {
+			return ErrStravaRateLimited
+		}
+		time.Sleep(delay)
+	}
+}
+
+// 配額用盡時可再次呼叫的時間，配額可用時回傳零值
+func (limiter *stravaRateLimiter) resumeAt(now time.Time) time.Time {
+	switch {
+	case now.Before(limiter.blockedUntil):
+		return limiter.blockedUntil
+	case limiter.dailyUsage >= limiter.dailyLimit:
+		return limiter.dailyWindow.AddDate(0, 0, 1)
+	case limiter.shortUsage >= limiter.shortLimit:
+		return limiter.shortWindow.Add(stravaShortWindow)
+	}
+
+	return time.Time{}
+}
+
+// 進入新的視窗時重設用量
+func (limiter *stravaRateLimiter) rollWindows(now time.Time) {
+	if shortWindow := now.Truncate(stravaShortWindow); !shortWindow.Equal(limiter.shortWindow) {
+		limiter.shortWindow = shortWindow
+		limiter.shortUsage = 0
+	}
+	if dailyWindow := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC); !dailyWindow.Equal(limiter.dailyWindow) {
+		limiter.dailyWindow = dailyWindow
+		limiter.dailyUsage = 0
+	}
+}
+
+// 依 Strava 回應更新配額，收到 429 時暫停到下一個視窗
+func (limiter *stravaRateLimiter) update(resp *http.Response) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now().UTC()
+	limiter.rollWindows(now)
+
+	// 格式為「15 分鐘,每日」
+	if shortLimit, dailyLimit, ok := parseStravaRateLimitHeader(resp.Header.Get("X-RateLimit-Limit")); ok {
+		limiter.shortLimit, limiter.dailyLimit = shortLimit, dailyLimit
+		limiter.updatedAt = now
+	}
+	if shortUsage, dailyUsage, ok := parseStravaRateLimitHeader(resp.Header.Get("X-RateLimit-Usage")); ok {
+		limiter.shortUsage, limiter.dailyUsage = shortUsage, dailyUsage
+		limiter.updatedAt = now
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if limiter.dailyUsage >= limiter.dailyLimit {
+			limiter.blockedUntil = limiter.dailyWindow.AddDate(0, 0, 1)
+		} else {
+			limiter.blockedUntil = limiter.shortWindow.Add(stravaShortWindow)
+		}
+	}
+}
+
+// 解析「15 分鐘,每日」格式的配額標頭
+func parseStravaRateLimitHeader(value string) (int, int, bool) {
+	short, daily, found := strings.Cut(value, ",")
+	if !found {
+		return 0, 0, false
+	}
+
+	shortValue, err := strconv.Atoi(strings.TrimSpace(short))
+	if err != nil {
+		return 0, 0, false
+	}
+	dailyValue, err := strconv.Atoi(strings.TrimSpace(daily))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return shortValue, dailyValue, true
+}
@@ -0,0 +1,137 @@
+package helper
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// 通行權杖到期前多久提前換發
+const stravaTokenRefreshMargin = 5 * time.Minute
+
+// 同一運動員同時只換發一次權杖
+var stravaTokenGroup singleflight.Group
+
+// 取得 Strava 權杖的到期時間
+func StravaTokenExpiry(tokens data.Token) *time.Time {
+	if tokens.ExpiresAt == 0 {
+		return nil
+	}
+
+	expiresAt := time.Unix(tokens.ExpiresAt, 0)
+	return &expiresAt
+}
+
+// 通行權杖是否仍可使用(未知到期時間的舊資料視為需要換發)
+func isStravaTokenFresh(myAthlete model.Athlete) bool {
+	return myAthlete.AccessToken != "" && myAthlete.TokenExpiresAt != nil &&
+		time.Until(*myAthlete.TokenExpiresAt) > stravaTokenRefreshMargin
+}
+
+// 取得有效的通行權杖，即將到期時提前換發，並更新傳入的運動員
+func GetStravaAccessToken(myAthlete *model.Athlete) (string, error) {
+	if isStravaTokenFresh(*myAthlete) {
+		return myAthlete.AccessToken, nil
+	}
+
+	return refreshStravaAccessToken(myAthlete, myAthlete.AccessToken)
+}
+
+// 換發通行權杖，staleToken 為呼叫端已知失效的權杖
+// 同一執行個體以 singleflight 合併請求；多個執行個體以條件更新寫入，呼叫 Strava 期間不鎖定資料列
+func refreshStravaAccessToken(myAthlete *model.Athlete, staleToken string) (string, error) {
+	value, err, _ := stravaTokenGroup.Do(strconv.FormatUint(myAthlete.ID, 10), func() (interface{}, error) {
+		athlete, version, ok := database.GetAthleteTokenVersion(myAthlete.ID)
+		if !ok {
+			return nil, fmt.Errorf("athlete %d not found", myAthlete.ID)
+		}
+
+		// 其他請求已換發新的權杖
+		if athlete.AccessToken != staleToken && isStravaTokenFresh(athlete) {
+			return athlete, nil
+		}
+		if athlete.RefreshToken == "" {
+			return nil, fmt.Errorf("athlete %d is not authorized", athlete.ID)
+		}
+
+		tokens, err := requestStravaToken(athlete)
+		if err != nil {
+			return nil, err
+		}
+
+		athlete.AccessToken = tokens.AccessToken
+		athlete.RefreshToken = tokens.RefreshToken
+		athlete.TokenExpiresAt = StravaTokenExpiry(tokens)
+		if database.SaveAthleteToken(&athlete, version) {
+			return athlete, nil
+		}
+
+		// 其他執行個體已先換發，改用資料庫中的權杖
+		athlete = database.GetAthleteByID(myAthlete.ID)
+		if athlete.AccessToken == "" {
+			return nil, fmt.Errorf("athlete %d is not authorized", myAthlete.ID)
+		}
+		return athlete, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	athlete := value.(model.Athlete)
+	myAthlete.AccessToken = athlete.AccessToken
+	myAthlete.RefreshToken = athlete.RefreshToken
+	myAthlete.TokenExpiresAt = athlete.TokenExpiresAt
+
+	return athlete.AccessToken, nil
+}
+
+// 以換發權杖向 Strava 取得新的權杖
+func requestStravaToken(myAthlete model.Athlete) (data.Token, error) {
+	clientID, clientSecret := stravaClientCredentials(myAthlete)
+	refreshTokenUrl := fmt.Sprintf("%sclient_id=%d&client_secret=%s&grant_type=refresh_token&refresh_token=%s", urlMap["refreshToken"], clientID, clientSecret, myAthlete.RefreshToken)
+
+	tokens, err := FetchStravaApi("POST", refreshTokenUrl, "", data.Token{})
+	if err != nil {
+		return tokens, err
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		return tokens, fmt.Errorf("strava returned no token for athlete %d", myAthlete.ID)
+	}
+
+	return tokens, nil
+}
+
+// 取得運動員使用的用戶端憑證，未自備 Strava 應用程式時使用本服務的憑證
+func stravaClientCredentials(myAthlete model.Athlete) (uint, string) {
+	if myAthlete.ClientSecret == "" {
+		return configuration.StravaClientID, configuration.StravaClientSecret
+	}
+	return myAthlete.ClientID, myAthlete.ClientSecret
+}
+
+// 以運動員的通行權杖呼叫 Strava API，權杖遭拒(例如被提前撤銷)時換發一次後重試
+func fetchStravaApiAsAthlete[T any](myAthlete *model.Athlete, method string, url string, result T) (T, error) {
+	accessToken, err := GetStravaAccessToken(myAthlete)
+	if err != nil {
+		return result, err
+	}
+
+	response, err := FetchStravaApi(method, url, accessToken, result)
+	if !isStravaStatus(err, http.StatusUnauthorized) {
+		return response, err
+	}
+
+	if accessToken, err = refreshStravaAccessToken(myAthlete, accessToken); err != nil {
+		return result, err
+	}
+
+	return FetchStravaApi(method, url, accessToken, result)
+}
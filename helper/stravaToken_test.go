@@ -0,0 +1,80 @@
+package helper
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+)
+
+// 模擬 Strava 換發權杖，beforeResponse 在回應前執行(模擬其他執行個體同時換發)
+type fakeStravaToken struct {
+	requests       int
+	beforeResponse func()
+}
+
+func (strava *fakeStravaToken) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.URL.Path != "/api/v3/oauth/token" {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	strava.requests++
+	if strava.beforeResponse != nil {
+		strava.beforeResponse()
+	}
+	_, _ = fmt.Fprintf(writer, `{"access_token":"new-access","refresh_token":"new-refresh","expires_at":%d}`, time.Now().Add(6*time.Hour).Unix())
+}
+
+// 新增通行權杖已過期的運動員
+func addExpiredAthlete(t *testing.T, athleteID uint64) *model.Athlete {
+	expiresAt := time.Now().Add(-time.Hour)
+	athlete := model.Athlete{ID: athleteID, AccessToken: "old-access", RefreshToken: "old-refresh", TokenExpiresAt: &expiresAt}
+	if !database.AddAthlete(&athlete) {
+		t.Fatal("add athlete")
+	}
+	return &athlete
+}
+
+func TestGetStravaAccessTokenRefreshesExpiredToken(t *testing.T) {
+	strava := &fakeStravaToken{}
+	useFakeStrava(t, strava)
+	athlete := addExpiredAthlete(t, 5001)
+
+	accessToken, err := GetStravaAccessToken(athlete)
+	if err != nil || accessToken != "new-access" {
+		t.Fatalf("access token: %q, %v", accessToken, err)
+	}
+
+	stored := database.GetAthleteByID(5001)
+	if stored.AccessToken != "new-access" || stored.RefreshToken != "new-refresh" || !isStravaTokenFresh(stored) {
+		t.Fatalf("token not saved: %+v", stored)
+	}
+
+	// 權杖仍有效時不再換發
+	if accessToken, _ := GetStravaAccessToken(&stored); accessToken != "new-access" || strava.requests != 1 {
+		t.Fatalf("access token %q after %d requests", accessToken, strava.requests)
+	}
+}
+
+func TestGetStravaAccessTokenKeepsConcurrentRefresh(t *testing.T) {
+	expiresAt := time.Now().Add(6 * time.Hour)
+	strava := &fakeStravaToken{beforeResponse: func() {
+		// 其他執行個體在 Strava 回應前已換發並寫入權杖
+		database.AddAthlete(&model.Athlete{ID: 5002, AccessToken: "other-access", RefreshToken: "other-refresh", TokenExpiresAt: &expiresAt})
+	}}
+	useFakeStrava(t, strava)
+	athlete := addExpiredAthlete(t, 5002)
+
+	accessToken, err := GetStravaAccessToken(athlete)
+	if err != nil || accessToken != "other-access" || athlete.RefreshToken != "other-refresh" {
+		t.Fatalf("access token: %q, %v", accessToken, err)
+	}
+
+	if stored := database.GetAthleteByID(5002); stored.AccessToken != "other-access" || stored.RefreshToken != "other-refresh" {
+		t.Fatalf("concurrent refresh overwritten: %+v", stored)
+	}
+}
@@ -0,0 +1,155 @@
+package helper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+)
+
+// 處理 Strava 推播事件
+// 推播請求沒有簽章，刪除與取消授權事件皆先向 Strava 確認，避免偽造的事件刪除資料
+func ProcessStravaEvent(event model.StravaEvent) error {
+	myAthlete := database.GetAthleteByID(event.OwnerID)
+	// 未連結的運動員不處理
+	if myAthlete.ID == 0 {
+		return nil
+	}
+
+	switch event.ObjectType {
+	case model.StravaObjectActivity:
+		// 已取消授權的運動員無法再取得活動
+		if myAthlete.RefreshToken == "" {
+			return nil
+		}
+		return syncStravaActivity(myAthlete, event.ObjectID)
+	case model.StravaObjectAthlete:
+		var updates map[string]interface{}
+		_ = json.Unmarshal([]byte(event.Updates), &updates)
+
+		if fmt.Sprint(updates["authorized"]) != "false" || myAthlete.RefreshToken == "" {
+			return nil
+		}
+		// 仍可取得運動員資料表示尚未取消授權
+		_, err := fetchStravaApiAsAthlete(&myAthlete, "GET", urlMap["getLoggedInAthlete"], data.Athlete{})
+		if !isStravaStatus(err, http.StatusBadRequest, http.StatusUnauthorized) {
+			return err
+		}
+		if !database.DeauthorizeAthlete(myAthlete.ID) {
+			return errors.New("Error deauthorizing athlete")
+		}
+	}
+
+	return nil
+}
+
+// 依 Strava 上的活動新增、更新或刪除活動紀錄
+func syncStravaActivity(myAthlete model.Athlete, activityID uint64) error {
+	url := strings.Replace(urlMap["getActivityById"], "{id}", strconv.FormatUint(activityID, 10), 1)
+
+	result, err := fetchStravaApiAsAthlete(&myAthlete, "GET", url, data.Activities{})
+
+	// 取不到活動，表示活動已刪除或不再可見
+	if isStravaStatus(err, http.StatusNotFound) {
+		if !database.DeleteActivity(myAthlete.ID, activityID) {
+			return errors.New("Error deleting activity")
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if result.Athlete.AthleteID != myAthlete.ID {
+		return errors.New("Activity belongs to another athlete")
+	}
+
+	if !database.SaveActivity(result) {
+		return errors.New("Error saving activity")
+	}
+
+	return nil
+}
+
+// 取得 Strava 推播訂閱
+func GetStravaSubscriptions(clientID uint, clientSecret string) ([]data.StravaSubscription, error) {
+	query := url.Values{
+		"client_id":     {strconv.FormatUint(uint64(clientID), 10)},
+		"client_secret": {clientSecret},
+	}
+
+	var subscriptions []data.StravaSubscription
+	err := sendStravaSubscriptionRequest("GET", urlMap["pushSubscriptions"]+"?"+query.Encode(), nil, &subscriptions)
+
+	return subscriptions, err
+}
+
+// 建立 Strava 推播訂閱，Strava 會先呼叫回呼網址驗證
+func CreateStravaSubscription(clientID uint, clientSecret string, callbackURL string, verifyToken string) (data.StravaSubscription, error) {
+	form := url.Values{
+		"client_id":     {strconv.FormatUint(uint64(clientID), 10)},
+		"client_secret": {clientSecret},
+		"callback_url":  {callbackURL},
+		"verify_token":  {verifyToken},
+	}
+
+	var subscription data.StravaSubscription
+	err := sendStravaSubscriptionRequest("POST", urlMap["pushSubscriptions"], form, &subscription)
+
+	return subscription, err
+}
+
+// 刪除 Strava 推播訂閱
+func DeleteStravaSubscription(clientID uint, clientSecret string, subscriptionID uint64) error {
+	query := url.Values{
+		"client_id":     {strconv.FormatUint(uint64(clientID), 10)},
+		"client_secret": {clientSecret},
+	}
+
+	return sendStravaSubscriptionRequest("DELETE", fmt.Sprintf("%s/%d?%s", urlMap["pushSubscriptions"], subscriptionID, query.Encode()), nil, nil)
+}
+
+// 呼叫 Strava 推播訂閱 API，失敗時回傳 Strava 的錯誤訊息
+func sendStravaSubscriptionRequest(method string, url string, form url.Values, result interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	request, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	if form != nil {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := doStravaRequest(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &StravaError{StatusCode: resp.StatusCode, Body: string(responseBody)}
+	}
+
+	if result == nil || len(responseBody) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(responseBody, result)
+}
@@ -0,0 +1,141 @@
+package helper
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+)
+
+// Strava 推播的事件內容
+const (
+	recordedActivityCreate = `{"aspect_type":"create","event_time":1549560669,"object_id":1360128428,"object_type":"activity","owner_id":134815,"subscription_id":120475,"updates":{}}`
+	recordedActivityUpdate = `{"aspect_type":"update","event_time":1516126040,"object_id":1360128428,"object_type":"activity","owner_id":134815,"subscription_id":120475,"updates":{"title":"Messy"}}`
+	recordedActivityDelete = `{"aspect_type":"delete","event_time":1516126140,"object_id":1360128428,"object_type":"activity","owner_id":134815,"subscription_id":120475,"updates":{}}`
+	recordedDeauthorize    = `{"aspect_type":"update","event_time":1516126240,"object_id":134815,"object_type":"athlete","owner_id":134815,"subscription_id":120475,"updates":{"authorized":"false"}}`
+)
+
+// Strava 活動 API 回應
+const recordedActivity = `{"id":1360128428,"name":"%s","athlete":{"id":134815},"sport_type":"Run","start_date":"2018-02-16T14:52:54Z","elapsed_time":4410,"moving_time":4207,"distance":28099,"total_elevation_gain":516,"average_speed":6.679,"max_speed":18.5,"average_cadence":78.5,"visibility":"everyone","map":{"summary_polyline":"ki{eFvqfiVqAWQIGEEKAYJgBVqDJ{BHa@jAkNJw@Pw@V{APs@^aABQAOEQGKoJ_FuJkFqAo@{A}@sH{DiAs@Q]?WVy@"}}`
+
+// 將推播內容轉換為儲存的事件
+func recordedEvent(t *testing.T, payload string) model.StravaEvent {
+	var myEvent data.StravaEvent
+	if err := json.Unmarshal([]byte(payload), &myEvent); err != nil {
+		t.Fatal(err)
+	}
+	updates, _ := json.Marshal(myEvent.Updates)
+
+	return model.StravaEvent{
+		SubscriptionID: myEvent.SubscriptionID,
+		OwnerID:        myEvent.OwnerID,
+		ObjectType:     myEvent.ObjectType,
+		ObjectID:       myEvent.ObjectID,
+		AspectType:     myEvent.AspectType,
+		EventTime:      myEvent.EventTime,
+		Updates:        string(updates),
+	}
+}
+
+// 模擬 Strava API 上的運動員與活動
+type fakeStrava struct {
+	activityName string // 活動名稱，空字串表示活動已刪除
+	authorized   bool   // 運動員是否仍授權本服務
+}
+
+func (strava *fakeStrava) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	switch {
+	case request.URL.Path == "/api/v3/activities/1360128428":
+		if strava.activityName == "" {
+			writer.WriteHeader(http.StatusNotFound)
+			_, _ = writer.Write([]byte(`{"message":"Record Not Found","errors":[{"resource":"Activity","field":"id","code":"invalid"}]}`))
+			return
+		}
+		_, _ = writer.Write([]byte(strings.Replace(recordedActivity, "%s", strava.activityName, 1)))
+	case request.URL.Path == "/api/v3/athlete":
+		if !strava.authorized {
+			writer.WriteHeader(http.StatusUnauthorized)
+			_, _ = writer.Write([]byte(`{"message":"Authorization Error","errors":[{"resource":"Athlete","field":"access_token","code":"invalid"}]}`))
+			return
+		}
+		_, _ = writer.Write([]byte(`{"id":134815,"firstname":"Jim","lastname":"Andy"}`))
+	case request.URL.Path == "/api/v3/oauth/token":
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = writer.Write([]byte(`{"message":"Bad Request","errors":[{"resource":"RefreshToken","field":"refresh_token","code":"invalid"}]}`))
+	default:
+		writer.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// 新增已授權的運動員
+func addAuthorizedAthlete(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	if !database.AddAthlete(&model.Athlete{ID: 134815, AccessToken: "access", RefreshToken: "refresh", TokenExpiresAt: &expiresAt}) {
+		t.Fatal("add athlete")
+	}
+}
+
+func TestProcessStravaActivityEvents(t *testing.T) {
+	strava := &fakeStrava{authorized: true}
+	useFakeStrava(t, strava)
+	addAuthorizedAthlete(t)
+
+	strava.activityName = "Morning Run"
+	if err := ProcessStravaEvent(recordedEvent(t, recordedActivityCreate)); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if activity := database.GetActivityByID("1360128428"); activity.Name != "Morning Run" || activity.AthleteID != 134815 {
+		t.Fatalf("activity not created: %+v", activity)
+	}
+
+	strava.activityName = "Messy"
+	if err := ProcessStravaEvent(recordedEvent(t, recordedActivityUpdate)); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if activity := database.GetActivityByID("1360128428"); activity.Name != "Messy" {
+		t.Fatalf("activity not updated: %+v", activity)
+	}
+
+	// 刪除事件先向 Strava 確認，活動仍存在時不刪除
+	if err := ProcessStravaEvent(recordedEvent(t, recordedActivityDelete)); err != nil {
+		t.Fatalf("forged delete: %v", err)
+	}
+	if database.GetActivityByID("1360128428").ID == 0 {
+		t.Fatal("activity deleted while it still exists on Strava")
+	}
+
+	strava.activityName = ""
+	if err := ProcessStravaEvent(recordedEvent(t, recordedActivityDelete)); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if database.GetActivityByID("1360128428").ID != 0 {
+		t.Fatal("activity not deleted")
+	}
+}
+
+func TestProcessStravaDeauthorizeEvent(t *testing.T) {
+	strava := &fakeStrava{authorized: true}
+	useFakeStrava(t, strava)
+	addAuthorizedAthlete(t)
+
+	// 偽造的取消授權事件：Strava 仍可取得運動員資料
+	if err := ProcessStravaEvent(recordedEvent(t, recordedDeauthorize)); err != nil {
+		t.Fatalf("forged deauthorize: %v", err)
+	}
+	if database.GetAthleteByID(134815).RefreshToken == "" {
+		t.Fatal("athlete deauthorized by a forged event")
+	}
+
+	strava.authorized = false
+	if err := ProcessStravaEvent(recordedEvent(t, recordedDeauthorize)); err != nil {
+		t.Fatalf("deauthorize: %v", err)
+	}
+	if athlete := database.GetAthleteByID(134815); athlete.RefreshToken != "" || athlete.AccessToken != "" {
+		t.Fatal("athlete tokens not cleared")
+	}
+}
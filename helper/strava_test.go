@@ -0,0 +1,65 @@
+package helper
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"Jimandy-Website-Backend/data"
+	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/model"
+)
+
+// Strava 運動員活動列表回應
+const recordedAthleteActivities = `[{"id":9001,"name":"Public Run","athlete":{"id":2024},"sport_type":"Run","start_date":"2024-03-02T06:00:00Z","distance":5000,"visibility":"everyone","map":{"summary_polyline":""}}]`
+
+func TestBackfillActivityVisibility(t *testing.T) {
+	useFakeStrava(t, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		// 補上可見度時需重新爬取所有活動
+		if request.URL.Path != "/api/v3/athlete/activities" || request.URL.Query().Get("after") != "" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if page, _ := strconv.Atoi(request.URL.Query().Get("page")); page > 1 {
+			_, _ = writer.Write([]byte(`[]`))
+			return
+		}
+		_, _ = writer.Write([]byte(recordedAthleteActivities))
+	}))
+
+	expiresAt := time.Now().Add(time.Hour)
+	if !database.AddAthlete(&model.Athlete{ID: 2024, AccessToken: "access", RefreshToken: "refresh", TokenExpiresAt: &expiresAt}) ||
+		!database.AddAthlete(&model.Athlete{ID: 2025}) {
+		t.Fatal("add athletes")
+	}
+
+	// 可見度欄位新增前爬取的活動
+	if !database.AddActivity([]data.Activities{
+		{ActivityID: 9001, Name: "Public Run", Athlete: data.Athlete{AthleteID: 2024}, Date: "2024-03-02T06:00:00Z"},
+		{ActivityID: 9002, Name: "Deleted Run", Athlete: data.Athlete{AthleteID: 2024}, Date: "2024-03-01T06:00:00Z"},
+		{ActivityID: 9003, Name: "Deauthorized Run", Athlete: data.Athlete{AthleteID: 2025}, Date: "2024-03-01T06:00:00Z"},
+	}) {
+		t.Fatal("add activities")
+	}
+
+	BackfillActivityVisibility()
+
+	tests := []struct {
+		activityID string
+		visibility string
+	}{
+		{"9001", model.VisibilityEveryone},
+		{"9002", model.VisibilityOnlyMe},
+		{"9003", model.VisibilityOnlyMe},
+	}
+	for _, test := range tests {
+		if activity := database.GetActivityByID(test.activityID); activity.Visibility != test.visibility {
+			t.Errorf("activity %s: visibility %q, want %q", test.activityID, activity.Visibility, test.visibility)
+		}
+	}
+
+	if athleteIDs := database.GetAthleteIDsWithoutActivityVisibility(); len(athleteIDs) != 0 {
+		t.Fatalf("athletes still without visibility: %v", athleteIDs)
+	}
+}
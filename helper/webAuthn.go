@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"time"
+
+	"Jimandy-Website-Backend/configuration"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// 通行金鑰驗證流程逾時
+const WebAuthnTimeout = 5 * time.Minute
+
+// 通行金鑰依賴方
+var WebAuthn *webauthn.WebAuthn
+
+// 依設定檔建立通行金鑰依賴方
+func SetupWebAuthn() error {
+	relyingParty, err := webauthn.New(&webauthn.Config{
+		RPID:          configuration.WebAuthnRPID,
+		RPDisplayName: configuration.WebAuthnRPName,
+		RPOrigins:     configuration.WebAuthnRPOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: WebAuthnTimeout},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: WebAuthnTimeout},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	WebAuthn = relyingParty
+
+	return nil
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
-	"log"
-	"os"
-	"path/filepath"
+	"Jimandy-Website-Backend/api"
 	"Jimandy-Website-Backend/configuration"
 	"Jimandy-Website-Backend/database"
+	"Jimandy-Website-Backend/helper"
 	"Jimandy-Website-Backend/router"
+	"log"
+	"os"
+	"path/filepath"
 )
 
 func main() {
@@ -16,16 +18,62 @@ func main() {
 	configuration.ExecutPath = ExecutPath
 
 	configuration.ReadConfiguration() // 讀取設定檔
-	
+	helper.SetupMailer()              // 設定寄信方式
+	helper.SetupIdentityProviders()   // 設定外部身分提供者
+
+	// 執行管理指令後結束
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	// 檢查設定值
+	if err := configuration.ValidateConfiguration(); err != nil {
+		log.Fatalln("Invalid configuration:", err)
+	}
+
+	// 資料庫只保存權杖雜湊，需要雜湊金鑰
+	if err := database.SetupTokenHashKey(); err != nil {
+		log.Fatalln("Invalid token hash key configuration:", err)
+	}
+
+	// 載入權杖簽章金鑰
+	if err := helper.SetupSigningKeys(); err != nil {
+		log.Fatalln("Invalid signing key configuration:", err)
+	}
+
+	// 載入加密 Strava 憑證的金鑰
+	if err := database.SetupEncryptionKeys(); err != nil {
+		log.Fatalln("Invalid encryption key configuration:", err)
+	}
+
+	// 設定通行金鑰依賴方
+	if err := helper.SetupWebAuthn(); err != nil {
+		log.Fatalln("Invalid WebAuthn configuration:", err)
+	}
+
 	log.Println("Opening Project DB...")
 
+	// 其他執行個體異動權限時清除本機的權限快取
+	database.OnPermissionChange(api.ClearLocalPermissionCache)
+
 	// 連線資料庫
 	database.Open()
 
+	// 處理 Strava 推播事件
+	api.StartStravaEventWorker()
+
+	// 補上舊活動的 Strava 可見度
+	go helper.BackfillActivityVisibility()
+
 	log.Println("Starting Project...")
 
+	// 多個執行個體時以資料庫共用限流狀態
+	if configuration.RateLimitStore == "postgres" {
+		helper.Limiter.Store = database.NewRateLimitStore()
+	}
+
 	// 監聽網頁服務
 	router.Run()
 
 	log.Println("Stoping Project...")
-}
\ No newline at end of file
+}
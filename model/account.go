@@ -1,6 +1,10 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 const (
 	Disabled int8 = iota
@@ -13,6 +17,14 @@ type Account struct {
 
 	Name         string `gorm:"comment:名稱"`
 	Email        string `gorm:"unique;not null;size:64;comment:電子郵件"`
-	Status       int8   `gorm:"index;comment:狀態 0停用 1啟用;default:1"`
+	PasswordHash string `gorm:"comment:密碼雜湊" json:"-"`
+	Status       int8   `gorm:"index;comment:狀態 0停用 1啟用 2未啟用;default:1"`
 	IsAdmin      int    `gorm:"comment:是否為管理員 1是 0否"`
+	Roles        []Role `gorm:"many2many:account_roles" json:"-"`
+
+	TOTPSecret   string `gorm:"comment:兩步驟驗證金鑰" json:"-"`
+	TOTPEnabled  bool   `gorm:"default:false;comment:是否啟用兩步驟驗證"`
+	TOTPLastStep int64  `gorm:"default:0;comment:最後使用的驗證碼週期" json:"-"`
+
+	DeletionScheduledAt *time.Time `gorm:"index;comment:預定刪除時間"`
 }
@@ -24,6 +24,7 @@ type Activity struct {
 	MaxWatts           int       `gorm:"comment:最大功率(bpm)"`
 	AverageTemperature int       `gorm:"comment:平均溫度(°C)"`
 	Polyline           string    `gorm:"comment:路線"`
+	Visibility         string    `gorm:"size:16;comment:Strava 可見度"`
 	Laps               []Lap     `gorm:"foreignKey:ActivityID"`
 }
 
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// 個人存取金鑰前綴
+const APIKeyPrefix = "pat_"
+
+// 個人存取金鑰，供腳本與整合服務呼叫 API
+type APIKey struct {
+	ID         uint       `gorm:"primarykey"`
+	AccountID  uint       `gorm:"index;not null;comment:帳號ID"`
+	Name       string     `gorm:"size:64;comment:名稱"`
+	Prefix     string     `gorm:"size:16;comment:金鑰開頭(顯示用)"`
+	KeyHash    string     `gorm:"uniqueIndex;size:64;not null;comment:金鑰雜湊"`
+	Scopes     string     `gorm:"comment:授權範圍(逗號分隔)"`
+	CreatedAt  time.Time  `gorm:"comment:建立時間"`
+	ExpiresAt  *time.Time `gorm:"comment:到期時間，空值表示不過期"`
+	LastUsedAt *time.Time `gorm:"comment:最後使用時間"`
+	LastUsedIP string     `gorm:"size:64;comment:最後使用 IP"`
+	RevokedAt  *time.Time `gorm:"comment:撤銷時間"`
+}
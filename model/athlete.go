@@ -1,14 +1,36 @@
 package model
 
+import (
+	"time"
+
+	"Jimandy-Website-Backend/data"
+)
+
 // 運動員
 type Athlete struct {
-	ID                uint64   `gorm:"primarykey"`
-	AccountID         uint     `gorm:"comment:帳號主鍵"`
-	ClientID          uint     `gorm:"comment:用戶端 ID"`
-	ClientSecret      string   `gorm:"comment:用戶端密碼"`
-	AuthorizationCode string   `gorm:"comment:授權代碼"`
-	AccessToken       string   `gorm:"comment:通行權杖"`
-	RefreshToken      string   `gorm:"comment:換發權杖"`
-	RunLap            Lap      `gorm:"foreignKey:AthleteID"`
-	RunActivity       Activity `gorm:"foreignKey:AthleteID"`
+	ID                uint64     `gorm:"primarykey"`
+	AccountID         uint       `gorm:"comment:帳號主鍵"`
+	ClientID          uint       `gorm:"comment:用戶端 ID"`
+	ClientSecret      string     `gorm:"serializer:encrypted;comment:用戶端密碼(加密)" json:"-"`
+	AuthorizationCode string     `gorm:"serializer:encrypted;comment:授權代碼(加密)" json:"-"`
+	AccessToken       string     `gorm:"serializer:encrypted;comment:通行權杖(加密)" json:"-"`
+	RefreshToken      string     `gorm:"serializer:encrypted;comment:換發權杖(加密)" json:"-"`
+	TokenExpiresAt    *time.Time `gorm:"comment:通行權杖到期時間" json:"-"`
+	IsPublic          bool       `gorm:"default:false;comment:是否公開 Strava 可見度為所有人的活動"`
+	RunLap            Lap        `gorm:"foreignKey:AthleteID"`
+	RunActivity       Activity   `gorm:"foreignKey:AthleteID"`
+}
+
+// 可回傳給前端的運動員資料(不含 Strava 憑證)
+func (athlete Athlete) Public() *data.PublicAthlete {
+	if athlete.ID == 0 {
+		return nil
+	}
+
+	return &data.PublicAthlete{
+		ID:        athlete.ID,
+		AccountID: athlete.AccountID,
+		IsPublic:  athlete.IsPublic,
+		Connected: athlete.RefreshToken != "",
+	}
 }
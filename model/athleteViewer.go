@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// 活動可見度
+const (
+	VisibilityEveryone = "everyone" // Strava 可見度為所有人
+	VisibilityOnlyMe   = "only_me"  // Strava 可見度為僅限本人
+)
+
+// 運動員授權可檢視活動的帳號
+type AthleteViewer struct {
+	ID        uint      `gorm:"primarykey"`
+	AthleteID uint64    `gorm:"uniqueIndex:idx_athlete_viewer;comment:運動員主鍵"`
+	AccountID uint      `gorm:"uniqueIndex:idx_athlete_viewer;comment:可檢視的帳號主鍵"`
+	CreatedAt time.Time `gorm:"comment:建立時間"`
+}
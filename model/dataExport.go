@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// 個人資料匯出狀態
+const (
+	ExportPending   = "pending"   // 等待中
+	ExportRunning   = "running"   // 產生中
+	ExportCompleted = "completed" // 已完成
+	ExportFailed    = "failed"    // 失敗
+)
+
+// 個人資料匯出
+type DataExport struct {
+	ID          string     `gorm:"primarykey;size:36;comment:匯出 ID"`
+	AccountID   uint       `gorm:"index;not null;comment:帳號ID"`
+	Status      string     `gorm:"size:16;comment:狀態"`
+	FilePath    string     `gorm:"comment:壓縮檔路徑" json:"-"`
+	Size        int64      `gorm:"comment:檔案大小(bytes)"`
+	CreatedAt   time.Time  `gorm:"comment:建立時間"`
+	CompletedAt *time.Time `gorm:"comment:完成時間"`
+	ExpiresAt   *time.Time `gorm:"index;comment:下載期限"`
+}
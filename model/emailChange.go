@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// 變更電子郵件申請，新舊電子郵件都確認後才變更
+type EmailChange struct {
+	ID             uint       `gorm:"primarykey"`
+	AccountID      uint       `gorm:"index;not null;comment:帳號ID"`
+	OldEmail       string     `gorm:"size:64;comment:原電子郵件"`
+	NewEmail       string     `gorm:"size:64;comment:新電子郵件"`
+	OldTokenHash   string     `gorm:"unique;comment:原電子郵件確認權杖雜湊"`
+	NewTokenHash   string     `gorm:"unique;comment:新電子郵件確認權杖雜湊"`
+	OldConfirmedAt *time.Time `gorm:"comment:原電子郵件確認時間"`
+	NewConfirmedAt *time.Time `gorm:"comment:新電子郵件確認時間"`
+	CreatedAt      time.Time  `gorm:"comment:建立時間"`
+	ExpiresAt      time.Time  `gorm:"index;comment:過期時間"`
+	CompletedAt    *time.Time `gorm:"comment:完成時間"`
+}
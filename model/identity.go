@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// 外部身分提供者連結
+type Identity struct {
+	ID        uint      `gorm:"primarykey"`
+	AccountID uint      `gorm:"index;comment:帳號主鍵"`
+	Provider  string    `gorm:"uniqueIndex:idx_identity_provider_subject;size:32;comment:身分提供者"`
+	Subject   string    `gorm:"uniqueIndex:idx_identity_provider_subject;comment:提供者使用者識別"`
+	Email     string    `gorm:"comment:提供者電子郵件"`
+	CreatedAt time.Time `gorm:"comment:建立時間"`
+}
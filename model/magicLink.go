@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// 免密碼登入連結
+type MagicLink struct {
+	ID        uint       `gorm:"primarykey"`
+	Email     string     `gorm:"index;size:64;comment:電子郵件"`
+	Name      string     `gorm:"comment:名稱"`
+	TokenHash string     `gorm:"unique;comment:連結權杖雜湊"`
+	CreatedAt time.Time  `gorm:"comment:建立時間"`
+	ExpiresAt time.Time  `gorm:"index;comment:過期時間"`
+	UsedAt    *time.Time `gorm:"comment:使用時間"`
+}
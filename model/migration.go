@@ -1,17 +1,40 @@
 package model
 
 import (
+	"strings"
+	"time"
+
+	"Jimandy-Website-Backend/utils"
+
 	"gorm.io/gorm"
 )
 
 // 自動遷移資料庫
 func AutoMigrate(db *gorm.DB) {
+	migrateTable(db, &Permission{})
+	migrateTable(db, &Role{})
 	migrateTable(db, &Account{})
 	migrateTable(db, &Athlete{})
 	migrateTable(db, &Activity{})
 	migrateTable(db, &Lap{})
+	renameTokenFamily(db)
 	migrateTable(db, &Token{})
+	migrateTable(db, &Session{})
+	migrateTable(db, &TokenRevocation{})
+	migrateTable(db, &MagicLink{})
+	migrateTable(db, &Identity{})
+	migrateTable(db, &SecurityEvent{})
+	protectSecurityEvents(db)
+	migrateTable(db, &AthleteViewer{})
+	migrateTable(db, &RateLimitHit{})
+	migrateTable(db, &RecoveryCode{})
+	migrateTable(db, &WebAuthnCredential{})
+	migrateTable(db, &APIKey{})
+	migrateTable(db, &EmailChange{})
+	migrateTable(db, &DataExport{})
+	migrateTable(db, &StravaEvent{})
 
+	backfillTokens(db)
 	checkTableData(db)
 }
 
@@ -23,6 +46,103 @@ func migrateTable(db *gorm.DB, structure interface{}) {
 	_ = db.AutoMigrate(structure)
 }
 
+// 安全事件只可新增，禁止修改既有紀錄
+func protectSecurityEvents(db *gorm.DB) {
+	db.Exec(`CREATE OR REPLACE FUNCTION forbid_security_event_update() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'security_events is append-only';
+END;
+$$ LANGUAGE plpgsql`)
+	db.Exec("DROP TRIGGER IF EXISTS security_events_append_only ON security_events")
+	db.Exec("CREATE TRIGGER security_events_append_only BEFORE UPDATE ON security_events FOR EACH ROW EXECUTE FUNCTION forbid_security_event_update()")
+}
+
+// 權杖家族欄位改名為工作階段 ID
+func renameTokenFamily(db *gorm.DB) {
+	if db.Migrator().HasColumn(&Token{}, "family_id") && !db.Migrator().HasColumn(&Token{}, "session_id") {
+		_ = db.Migrator().RenameColumn(&Token{}, "family_id", "session_id")
+	}
+}
+
+// 為舊權杖補上新欄位與工作階段
+func backfillTokens(db *gorm.DB) {
+	// 每筆舊權杖各自成為一個工作階段，並沿用原本的過期時間
+	db.Model(&Token{}).Where("session_id IS NULL OR session_id = ''").Update("session_id", gorm.Expr("id::text"))
+	db.Model(&Token{}).Where("refresh_expires_at IS NULL").Update("refresh_expires_at", gorm.Expr("expires_at"))
+
+	var tokens []struct {
+		SessionID        string
+		AccountID        uint
+		DeviceInfo       string
+		CreatedAt        time.Time
+		RefreshExpiresAt time.Time
+		IsRevoked        bool
+	}
+
+	columns := "session_id, account_id, created_at, refresh_expires_at, is_revoked"
+	// 舊版以 "UserAgent|IP" 記錄裝置資訊
+	if db.Migrator().HasColumn(&Token{}, "device_info") {
+		columns += ", device_info"
+	}
+	db.Model(&Token{}).Select(columns).Where("session_id NOT IN (?)", db.Model(&Session{}).Select("id")).Order("created_at").Find(&tokens)
+
+	sessions := map[string]*Session{}
+	var sessionIDs []string
+	for _, token := range tokens {
+		session, found := sessions[token.SessionID]
+		if !found {
+			userAgent, ip, _ := strings.Cut(token.DeviceInfo, "|")
+			browser, os, device := utils.ParseUserAgent(userAgent)
+			session = &Session{
+				ID:          token.SessionID,
+				AccountID:   token.AccountID,
+				Name:        browser + " on " + os,
+				Browser:     browser,
+				OS:          os,
+				Device:      device,
+				UserAgent:   userAgent,
+				LastIP:      ip,
+				FirstSeenAt: token.CreatedAt,
+			}
+			sessions[token.SessionID] = session
+			sessionIDs = append(sessionIDs, token.SessionID)
+		}
+
+		session.LastSeenAt = token.CreatedAt
+		session.ExpiresAt = token.RefreshExpiresAt
+		if token.IsRevoked {
+			revokedAt := token.CreatedAt
+			session.RevokedAt = &revokedAt
+		}
+	}
+
+	for _, sessionID := range sessionIDs {
+		_ = db.Create(sessions[sessionID]).Error
+	}
+}
+
 // 檢查資料表有無資料
 func checkTableData(db *gorm.DB) {
+	seedRoles(db)
+}
+
+// 建立預設權限與角色，並補上預設角色缺少的預設權限
+func seedRoles(db *gorm.DB) {
+	permissions := map[string]Permission{}
+	for _, permission := range defaultPermissions {
+		_ = db.Where(Permission{Name: permission.Name}).Attrs(permission).FirstOrCreate(&permission).Error
+		permissions[permission.Name] = permission
+	}
+
+	for name, role := range defaultRoles {
+		if db.Where(Role{Name: name}).Attrs(role).FirstOrCreate(&role).Error != nil {
+			continue
+		}
+
+		var rolePermissions []Permission
+		for _, permissionName := range defaultRolePermissions[name] {
+			rolePermissions = append(rolePermissions, permissions[permissionName])
+		}
+		_ = db.Model(&role).Association("Permissions").Append(rolePermissions)
+	}
 }
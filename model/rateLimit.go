@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// 限流紀錄，多個執行個體共用
+type RateLimitHit struct {
+	ID    uint      `gorm:"primarykey"`
+	Key   string    `gorm:"index:idx_rate_limit_key_hit;size:128;comment:限流鍵值"`
+	HitAt time.Time `gorm:"index:idx_rate_limit_key_hit;comment:呼叫時間"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// 兩步驟驗證備用碼，每組只能使用一次
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey"`
+	AccountID uint       `gorm:"index;not null;comment:帳號ID"`
+	CodeHash  string     `gorm:"size:64;not null;comment:備用碼雜湊"`
+	CreatedAt time.Time  `gorm:"comment:建立時間"`
+	UsedAt    *time.Time `gorm:"comment:使用時間"`
+}
@@ -0,0 +1,50 @@
+package model
+
+// 權限名稱
+const (
+	PermissionAthleteWrite   = "athlete:write"   // 連結運動員
+	PermissionActivitiesRead = "activities:read" // 讀取活動紀錄
+	PermissionAccountsRead   = "accounts:read"   // 查詢帳號
+	PermissionAccountsWrite  = "accounts:write"  // 管理帳號
+)
+
+// 角色名稱
+const (
+	RoleAdmin = "admin" // 管理員，IsAdmin 帳號自動擁有
+	RoleUser  = "user"  // 一般使用者，所有帳號預設擁有
+)
+
+// 權限
+type Permission struct {
+	ID          uint   `gorm:"primarykey"`
+	Name        string `gorm:"unique;not null;size:64;comment:權限名稱"`
+	Description string `gorm:"comment:說明"`
+}
+
+// 角色
+type Role struct {
+	ID          uint         `gorm:"primarykey"`
+	Name        string       `gorm:"unique;not null;size:64;comment:角色名稱"`
+	Description string       `gorm:"comment:說明"`
+	IsDefault   bool         `gorm:"default:false;comment:是否為所有帳號預設角色"`
+	Permissions []Permission `gorm:"many2many:role_permissions"`
+}
+
+// 預設權限
+var defaultPermissions = []Permission{
+	{Name: PermissionAthleteWrite, Description: "連結運動員"},
+	{Name: PermissionActivitiesRead, Description: "讀取活動紀錄"},
+	{Name: PermissionAccountsRead, Description: "查詢帳號"},
+	{Name: PermissionAccountsWrite, Description: "管理帳號"},
+}
+
+// 預設角色與其權限
+var defaultRoles = map[string]Role{
+	RoleAdmin: {Name: RoleAdmin, Description: "管理員"},
+	RoleUser:  {Name: RoleUser, Description: "一般使用者", IsDefault: true},
+}
+
+var defaultRolePermissions = map[string][]string{
+	RoleAdmin: {PermissionAthleteWrite, PermissionActivitiesRead, PermissionAccountsRead, PermissionAccountsWrite},
+	RoleUser:  {PermissionAthleteWrite, PermissionActivitiesRead},
+}
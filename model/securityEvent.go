@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// 安全事件類型
+const (
+	SecurityEventLogin             = "login"               // 登入
+	SecurityEventTokenRefresh      = "token_refresh"       // 刷新權杖
+	SecurityEventLogout            = "logout"              // 登出
+	SecurityEventDeviceRevoked     = "device_revoked"      // 登出特定裝置
+	SecurityEventAthleteLinked     = "athlete_linked"      // 連結 Strava 運動員
+	SecurityEventAdminAction       = "admin_action"        // 管理員操作
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse" // 重複使用已換發的刷新權杖
+	SecurityEventTwoFactorEnabled  = "two_factor_enabled"  // 啟用兩步驟驗證
+	SecurityEventTwoFactorDisabled = "two_factor_disabled" // 停用兩步驟驗證
+	SecurityEventRecoveryCodeUsed  = "recovery_code_used"  // 使用備用碼登入
+	SecurityEventPasskeyAdded      = "passkey_added"       // 新增通行金鑰
+	SecurityEventPasskeyRemoved    = "passkey_removed"     // 移除通行金鑰
+	SecurityEventPasskeyCloned     = "passkey_cloned"      // 通行金鑰簽章計數異常(可能遭複製)
+	SecurityEventProfileUpdated    = "profile_updated"     // 修改個人資料
+	SecurityEventEmailChanged      = "email_changed"       // 變更電子郵件
+	SecurityEventAccountDeletion   = "account_deletion"    // 刪除帳號
+	SecurityEventDataExport        = "data_export"         // 匯出個人資料
+)
+
+// 安全事件結果
+const (
+	SecurityOutcomeSuccess = "success" // 成功
+	SecurityOutcomeFailure = "failure" // 失敗
+)
+
+// 安全事件(只新增不修改，超過保留期限後刪除)
+type SecurityEvent struct {
+	ID        uint      `gorm:"primarykey"`
+	AccountID uint      `gorm:"index;comment:事件所屬帳號主鍵，未知帳號為 0"`
+	ActorID   uint      `gorm:"index;comment:執行者帳號主鍵(管理員操作時與所屬帳號不同)"`
+	Type      string    `gorm:"index;size:32;comment:事件類型"`
+	Outcome   string    `gorm:"index;size:16;comment:結果 success 或 failure"`
+	IP        string    `gorm:"size:64;comment:來源 IP"`
+	UserAgent string    `gorm:"comment:使用者代理"`
+	Detail    string    `gorm:"comment:詳細資訊"`
+	CreatedAt time.Time `gorm:"index;comment:建立時間"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// 登入工作階段(裝置)，同一次登入換發的權杖屬於同一工作階段
+type Session struct {
+	ID          string     `gorm:"primarykey;size:36;comment:工作階段 ID"`
+	AccountID   uint       `gorm:"index;comment:帳號主鍵"`
+	Name        string     `gorm:"comment:顯示名稱"`
+	Browser     string     `gorm:"size:64;comment:瀏覽器"`
+	OS          string     `gorm:"size:64;comment:作業系統"`
+	Device      string     `gorm:"size:32;comment:裝置類型"`
+	UserAgent   string     `gorm:"comment:使用者代理"`
+	LastIP      string     `gorm:"size:64;comment:最後 IP"`
+	FirstSeenAt time.Time  `gorm:"comment:登入時間"`
+	LastSeenAt  time.Time  `gorm:"comment:最後使用時間"`
+	ExpiresAt   time.Time  `gorm:"index;comment:過期時間(最新刷新權杖的過期時間)"`
+	RevokedAt   *time.Time `gorm:"comment:撤銷時間"`
+}
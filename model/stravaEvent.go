@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Strava 推播事件物件類型
+const (
+	StravaObjectActivity = "activity" // 活動
+	StravaObjectAthlete  = "athlete"  // 運動員
+)
+
+// Strava 推播事件類型
+const (
+	StravaAspectCreate = "create" // 新增
+	StravaAspectUpdate = "update" // 修改
+	StravaAspectDelete = "delete" // 刪除
+)
+
+// Strava 推播事件(同一事件重送時以唯一索引去除重複)
+type StravaEvent struct {
+	ID             uint       `gorm:"primarykey"`
+	SubscriptionID uint64     `gorm:"uniqueIndex:idx_strava_event;comment:訂閱 ID"`
+	OwnerID        uint64     `gorm:"uniqueIndex:idx_strava_event;comment:運動員主鍵"`
+	ObjectType     string     `gorm:"size:16;uniqueIndex:idx_strava_event;comment:物件類型"`
+	ObjectID       uint64     `gorm:"uniqueIndex:idx_strava_event;comment:物件主鍵"`
+	AspectType     string     `gorm:"size:16;uniqueIndex:idx_strava_event;comment:事件類型"`
+	EventTime      int64      `gorm:"uniqueIndex:idx_strava_event;comment:事件時間(Unix)"`
+	Updates        string     `gorm:"comment:異動欄位(JSON)"`
+	ReceivedAt     time.Time  `gorm:"index;comment:接收時間"`
+	ProcessingAt   *time.Time `gorm:"comment:開始處理時間(逾時未完成時可重新處理)"`
+	ProcessedAt    *time.Time `gorm:"index;comment:處理完成時間"`
+	Attempts       int        `gorm:"default:0;comment:處理次數"`
+	Error          string     `gorm:"comment:最後一次處理錯誤"`
+}
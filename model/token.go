@@ -4,12 +4,14 @@ import "time"
 
 // Token 存儲用戶的 token 資訊
 type Token struct {
-	ID           uint      `gorm:"primarykey"`
-	AccountID    uint      `gorm:"index;comment:帳號主鍵"`
-	AccessToken  string    `gorm:"unique;comment:存取權杖"`
-	RefreshToken string    `gorm:"unique;comment:刷新權杖"`
-	DeviceInfo   string    `gorm:"comment:裝置資訊"`
-	CreatedAt    time.Time `gorm:"comment:建立時間"`
-	ExpiresAt    time.Time `gorm:"index;comment:過期時間"`
-	IsRevoked    bool      `gorm:"default:false;comment:是否已撤銷"`
+	ID               uint       `gorm:"primarykey"`
+	AccountID        uint       `gorm:"index;comment:帳號主鍵"`
+	SessionID        string     `gorm:"index;size:36;comment:工作階段 ID"`
+	AccessTokenHash  string     `gorm:"uniqueIndex;size:64;comment:存取權杖雜湊(HMAC-SHA256)"`
+	RefreshTokenHash string     `gorm:"uniqueIndex;size:64;comment:刷新權杖雜湊(HMAC-SHA256)"`
+	CreatedAt        time.Time  `gorm:"comment:建立時間(最後一次刷新時間)"`
+	ExpiresAt        time.Time  `gorm:"index;comment:存取權杖過期時間"`
+	RefreshExpiresAt time.Time  `gorm:"index;comment:刷新權杖過期時間"`
+	RotatedAt        *time.Time `gorm:"comment:換發時間(已被新權杖取代)"`
+	IsRevoked        bool       `gorm:"default:false;comment:是否已撤銷"`
 }
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// 帳號層級的權杖撤銷紀錄(停用或刪除帳號時新增)，重新啟動後載入，超過 access token 效期後刪除
+type TokenRevocation struct {
+	ID        uint      `gorm:"primarykey"`
+	AccountID uint      `gorm:"index;comment:帳號主鍵"`
+	RevokedAt time.Time `gorm:"index;comment:撤銷時間，此時間前簽發的權杖皆失效"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// 通行金鑰(WebAuthn 憑證)，每個帳號可有多把
+type WebAuthnCredential struct {
+	ID              uint       `gorm:"primarykey"`
+	AccountID       uint       `gorm:"index;not null;comment:帳號ID"`
+	Name            string     `gorm:"size:64;comment:顯示名稱"`
+	CredentialID    []byte     `gorm:"uniqueIndex;not null;comment:憑證ID"`
+	PublicKey       []byte     `gorm:"not null;comment:公開金鑰(COSE)"`
+	AttestationType string     `gorm:"size:32;comment:證明格式"`
+	Transports      string     `gorm:"comment:傳輸方式(逗號分隔)"`
+	AAGUID          []byte     `gorm:"comment:驗證器型號"`
+	SignCount       uint32     `gorm:"comment:簽章計數"`
+	UserVerified    bool       `gorm:"comment:註冊時是否驗證使用者"`
+	BackupEligible  bool       `gorm:"comment:是否可備份同步"`
+	BackupState     bool       `gorm:"comment:是否已備份同步"`
+	CreatedAt       time.Time  `gorm:"comment:建立時間"`
+	LastUsedAt      *time.Time `gorm:"comment:最後使用時間"`
+}
@@ -2,12 +2,14 @@ package router
 
 import (
 	"path/filepath"
+	"strings"
 
 	"Jimandy-Website-Backend/api"
 	"Jimandy-Website-Backend/configuration"
 	"Jimandy-Website-Backend/data"
 	"Jimandy-Website-Backend/database"
-	"Jimandy-Website-Backend/utils"
+	"Jimandy-Website-Backend/helper"
+	"Jimandy-Website-Backend/model"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
@@ -15,6 +17,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	jwtware "github.com/gofiber/jwt/v3"
+	"github.com/golang-jwt/jwt/v4"
 )
 
 const (
@@ -32,7 +35,7 @@ func Run() {
 	HttpApplication.Use(logger.New())   // 啟用日誌
 	HttpApplication.Use(cors.New(cors.Config{
 		AllowOrigins: "http://localhost:5173,https://jimandy-growth.com/",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
+		AllowMethods: "GET,POST,PUT,PATCH,DELETE,OPTIONS",
 		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
 	}))
 
@@ -45,21 +48,75 @@ func Run() {
 }
 
 func bindAuthorized() {
-	// 要求授權
+	// 個人存取金鑰與 access token 擇一驗證
+	HttpApplication.Use(apiKeyHandler)
 	HttpApplication.Use(jwtware.New(jwtware.Config{
-		SigningKey:     configuration.JWTKey,
-		SuccessHandler: jwtSuccessHandler, // 權杖驗證成功後檢查授權
+		Filter:         isAPIKeyRequest,     // 個人存取金鑰已由 apiKeyHandler 驗證
+		KeyFunc:        helper.TokenKeyFunc, // 依 kid 選擇驗證金鑰
+		SuccessHandler: jwtSuccessHandler,   // 權杖驗證成功後檢查授權
 	}))
 	apiGroup := HttpApplication.Group("")
 
-	apiGroup.Get("/api/currentuser", api.GetCurrentUser)   // 取得當前使用者資訊
-	apiGroup.Get("/api/devices", api.GetUserDevices)       // 取得用戶的所有裝置
-	apiGroup.Post("/api/devices/logout", api.LogoutDevice) // 登出特定裝置
-
-	// 綁定授權列表
+	// 綁定授權列表，有指定權限的路由先檢查權限(個人存取金鑰只能存取授權列表內的路由)
 	for _, acl := range AccessControlLists {
-		apiGroup.Add(acl.Method, acl.Path, acl.Handler)
+		if acl.Authorization == "" {
+			apiGroup.Add(acl.Method, acl.Path, sessionOnlyHandler, acl.Handler)
+			continue
+		}
+		apiGroup.Add(acl.Method, acl.Path, permissionHandler(acl.Authorization), acl.Handler)
+	}
+
+	// 以下路由只允許登入工作階段存取
+	HttpApplication.Use(sessionOnlyHandler)
+
+	apiGroup.Get("/api/currentuser", api.GetCurrentUser)                             // 取得當前使用者資訊
+	apiGroup.Patch("/api/me", api.UpdateProfile)                                     // 修改個人資料
+	apiGroup.Delete("/api/me", api.DeleteMe)                                         // 刪除帳號
+	apiGroup.Post("/api/me/reauth/passkey", api.BeginPasskeyReauthentication)        // 開始以通行金鑰重新驗證身分
+	apiGroup.Delete("/api/me/deletion", api.CancelDeleteMe)                          // 取消刪除帳號
+	apiGroup.Post("/api/me/email", api.RequestEmailChange)                           // 申請變更電子郵件
+	apiGroup.Get("/api/me/exports", api.GetDataExports)                              // 取得個人資料匯出紀錄
+	apiGroup.Post("/api/me/exports", api.RequestDataExport)                          // 申請匯出個人資料
+	apiGroup.Get("/api/me/exports/:id/download", api.DownloadDataExport)             // 下載個人資料匯出檔
+	apiGroup.Get("/api/devices", api.GetUserDevices)                                 // 取得用戶的所有裝置
+	apiGroup.Patch("/api/devices/:sessionId", api.RenameDevice)                      // 修改裝置顯示名稱
+	apiGroup.Post("/api/devices/:sessionId/logout", api.LogoutDevice)                // 登出特定裝置
+	apiGroup.Get("/api/me/permissions", api.GetMyPermissions)                        // 取得當前使用者的角色與權限
+	apiGroup.Get("/api/me/security-events", api.GetMySecurityEvents)                 // 取得當前使用者的安全事件
+	apiGroup.Get("/api/me/2fa", api.GetTwoFactorStatus)                              // 取得兩步驟驗證狀態
+	apiGroup.Post("/api/me/2fa/enroll", api.EnrollTwoFactor)                         // 開始設定兩步驟驗證
+	apiGroup.Post("/api/me/2fa/confirm", api.ConfirmTwoFactor)                       // 確認並啟用兩步驟驗證
+	apiGroup.Post("/api/me/2fa/recovery-codes", api.RegenerateRecoveryCodes)         // 重新產生備用碼
+	apiGroup.Delete("/api/me/2fa", api.DisableTwoFactor)                             // 停用兩步驟驗證
+	apiGroup.Get("/api/me/passkeys", api.GetPasskeys)                                // 取得通行金鑰
+	apiGroup.Post("/api/me/passkeys/register/begin", api.BeginPasskeyRegistration)   // 開始註冊通行金鑰
+	apiGroup.Post("/api/me/passkeys/register/finish", api.FinishPasskeyRegistration) // 完成註冊通行金鑰
+	apiGroup.Patch("/api/me/passkeys/:id", api.RenamePasskey)                        // 修改通行金鑰顯示名稱
+	apiGroup.Delete("/api/me/passkeys/:id", api.DeletePasskey)                       // 移除通行金鑰
+	apiGroup.Get("/api/me/apikeys", api.GetAPIKeys)                                  // 取得個人存取金鑰
+	apiGroup.Post("/api/me/apikeys", api.AddAPIKey)                                  // 建立個人存取金鑰
+	apiGroup.Delete("/api/me/apikeys/:id", api.DeleteAPIKey)                         // 撤銷個人存取金鑰
+}
+
+// 檢查登入帳號是否擁有存取控制列表指定的權限，個人存取金鑰另須擁有該授權範圍
+func permissionHandler(permission string) fiber.Handler {
+	return func(context *fiber.Ctx) error {
+		accountID := uint(context.Locals("id").(float64))
+		if !api.HasPermission(accountID, permission) || !api.HasScope(context, permission) {
+			return context.SendStatus(fiber.StatusForbidden)
+		}
+
+		return context.Next()
+	}
+}
+
+// 依 IP 限制登入相關 API 的呼叫次數
+func rateLimitHandler(context *fiber.Ctx) error {
+	if !api.AllowRequest(context, "ip:"+context.IP(), configuration.RateLimitPerIP) {
+		return context.SendStatus(fiber.StatusTooManyRequests)
 	}
+
+	return context.Next()
 }
 
 // 未找到路徑轉到首頁
@@ -80,18 +137,48 @@ func notFoundHandler(context *fiber.Ctx, err error) error {
 
 // 設定路由
 func setupRoute() {
-	HttpApplication.Post("/api/login", api.Login)          // 取得帳號權杖
-	HttpApplication.Post("/api/refresh", api.RefreshToken) // 刷新 access token
-	HttpApplication.Post("/api/logout", api.Logout)        // 登出
+	HttpApplication.Get("/.well-known/jwks.json", api.GetJWKS) // 取得驗證權杖用的公開金鑰
+
+	// Strava 推播訂閱
+	HttpApplication.Get("/api/strava/webhook", api.VerifyStravaWebhook)   // 驗證 Strava 推播訂閱
+	HttpApplication.Post("/api/strava/webhook", api.ReceiveStravaWebhook) // 接收 Strava 推播事件
+
+	// 登入相關 API 依 IP 限流
+	HttpApplication.Post("/api/register", rateLimitHandler, api.Register)                                 // 註冊帳號
+	HttpApplication.Post("/api/activate", rateLimitHandler, api.ActivateAccount)                          // 啟用帳號
+	HttpApplication.Post("/api/activate/resend", rateLimitHandler, api.ResendActivation)                  // 重新寄送帳號啟用連結
+	HttpApplication.Post("/api/email-change/confirm", rateLimitHandler, api.ConfirmEmailChange)           // 確認變更電子郵件
+	HttpApplication.Post("/api/login", rateLimitHandler, api.Login)                                       // 取得帳號權杖
+	HttpApplication.Post("/api/login/2fa", rateLimitHandler, api.VerifyTwoFactor)                         // 兩步驟驗證登入
+	HttpApplication.Post("/api/login/passkey/begin", rateLimitHandler, api.BeginPasskeyLogin)             // 開始以通行金鑰登入
+	HttpApplication.Post("/api/login/passkey/finish", rateLimitHandler, api.FinishPasskeyLogin)           // 完成以通行金鑰登入
+	HttpApplication.Post("/api/login/magiclink", rateLimitHandler, api.RequestMagicLink)                  // 寄送免密碼登入連結
+	HttpApplication.Post("/api/login/magiclink/verify", rateLimitHandler, api.VerifyMagicLink)            // 驗證免密碼登入連結
+	HttpApplication.Get("/api/login/:provider", rateLimitHandler, api.RedirectIdentityProvider)           // 導向外部身分提供者
+	HttpApplication.Post("/api/login/:provider/callback", rateLimitHandler, api.IdentityProviderCallback) // 外部身分提供者授權回呼
+	HttpApplication.Post("/api/refresh", rateLimitHandler, api.RefreshToken)                              // 刷新 access token
+	HttpApplication.Post("/api/logout", rateLimitHandler, api.Logout)                                     // 登出
 
 	bindAuthorized() // 綁定授權
 }
 
 // AccessControlLists 存取控制列表
 var AccessControlLists = []data.AccessControlList{
-	AccessControlListFactory("/api/athlete", fiber.MethodPost, "", api.AddAthlete),                                    // 新增運動員
-	AccessControlListFactory("/api/activities/:athleteid", fiber.MethodGet, "", api.GetActivities),                    // 取得所有活動紀錄
-	AccessControlListFactory("/api/activities/laps/:athleteid/:activityid", fiber.MethodGet, "", api.GetActivityLaps), // 取得活動紀錄圈數
+	AccessControlListFactory("/api/strava/connect", fiber.MethodGet, model.PermissionAthleteWrite, api.ConnectStrava),                             // 導向 Strava 授權頁以連結運動員
+	AccessControlListFactory("/api/strava/callback", fiber.MethodPost, model.PermissionAthleteWrite, api.StravaCallback),                          // Strava 授權回呼
+	AccessControlListFactory("/api/athlete/viewers", fiber.MethodGet, model.PermissionAthleteWrite, api.GetAthleteViewers),                        // 取得授權檢視活動的帳號
+	AccessControlListFactory("/api/athlete/viewers", fiber.MethodPost, model.PermissionAthleteWrite, api.AddAthleteViewer),                        // 授權帳號檢視活動
+	AccessControlListFactory("/api/athlete/viewers/:accountid", fiber.MethodDelete, model.PermissionAthleteWrite, api.DeleteAthleteViewer),        // 取消帳號檢視活動的授權
+	AccessControlListFactory("/api/athlete/visibility", fiber.MethodPut, model.PermissionAthleteWrite, api.SetAthleteVisibility),                  // 設定是否公開活動
+	AccessControlListFactory("/api/activities/:athleteid", fiber.MethodGet, model.PermissionActivitiesRead, api.GetActivities),                    // 取得所有活動紀錄
+	AccessControlListFactory("/api/activities/laps/:athleteid/:activityid", fiber.MethodGet, model.PermissionActivitiesRead, api.GetActivityLaps), // 取得活動紀錄圈數
+	AccessControlListFactory("/api/admin/security-events", fiber.MethodGet, model.PermissionAccountsRead, api.GetSecurityEvents),                  // 查詢安全事件
+	AccessControlListFactory("/api/admin/accounts", fiber.MethodGet, model.PermissionAccountsRead, api.GetAccounts),                               // 搜尋帳號
+	AccessControlListFactory("/api/admin/accounts/:accountid", fiber.MethodGet, model.PermissionAccountsRead, api.GetAccount),                     // 取得帳號詳細資料
+	AccessControlListFactory("/api/admin/accounts/:accountid/status", fiber.MethodPut, model.PermissionAccountsWrite, api.SetAccountStatus),       // 停用或啟用帳號
+	AccessControlListFactory("/api/admin/accounts/:accountid/logout", fiber.MethodPost, model.PermissionAccountsWrite, api.LogoutAccount),         // 強制登出帳號
+	AccessControlListFactory("/api/admin/accounts/:accountid/admin", fiber.MethodPut, model.PermissionAccountsWrite, api.SetAccountAdmin),         // 設定管理員身分
+	AccessControlListFactory("/api/admin/strava/rate-limit", fiber.MethodGet, model.PermissionAccountsRead, api.GetStravaRateLimit),               // 查詢 Strava API 配額使用狀況
 }
 
 // 存取控制列表 工廠方法
@@ -100,19 +187,64 @@ func AccessControlListFactory(path, method string, authorization string, handler
 }
 
 // 權杖驗證成功後檢查授權
+// 簽章與逾時已由 jwtware 驗證，這裡只檢查權杖類型與記憶體中的撤銷清單，不查詢資料庫
 func jwtSuccessHandler(context *fiber.Ctx) error {
-	token := api.GetTokenFromHeader(context)
-	if !api.IsTokenValid(token) {
+	token, ok := context.Locals("user").(*jwt.Token)
+	if !ok {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	tokenType, _ := claims["typ"].(string)
+	sessionID, _ := claims["sid"].(string)
+	accountID, _ := claims["id"].(float64)
+	issuedAt, _ := claims["iat"].(float64)
+	// 舊權杖沒有毫秒簽發時間，視為該秒開始時簽發
+	issuedAtMilli := int64(issuedAt) * 1000
+	if value, ok := claims["iat_ms"].(float64); ok {
+		issuedAtMilli = int64(value)
+	}
+	if tokenType != "access" || sessionID == "" || accountID == 0 {
+		return context.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	// 登出、撤銷裝置、撤銷帳號所有工作階段或停用帳號後立即失效
+	if database.IsTokenRevoked(sessionID, uint(accountID), issuedAtMilli) {
 		return context.SendStatus(fiber.StatusUnauthorized)
 	}
 
-	// 從資料庫獲取 token 信息
-	dbToken := database.GetTokenByAccessToken(token)
-	if dbToken == nil || dbToken.IsRevoked || utils.GetCurrentTime().After(dbToken.ExpiresAt) {
+	context.Locals("id", accountID)  // 登入帳號
+	context.Locals("sid", sessionID) // 工作階段 ID
+
+	return context.Next()
+}
+
+// 是否以個人存取金鑰呼叫
+func isAPIKeyRequest(context *fiber.Ctx) bool {
+	return strings.HasPrefix(api.GetTokenFromHeader(context), model.APIKeyPrefix)
+}
+
+// 驗證個人存取金鑰，與 jwtSuccessHandler 設定相同的登入資訊
+func apiKeyHandler(context *fiber.Ctx) error {
+	if !isAPIKeyRequest(context) {
+		return context.Next()
+	}
+
+	if !api.AuthenticateAPIKey(context) {
 		return context.SendStatus(fiber.StatusUnauthorized)
 	}
 
-	context.Locals("id", float64(dbToken.AccountID)) // 登入帳號
+	return context.Next()
+}
+
+// 拒絕以個人存取金鑰存取帳號管理等路由
+func sessionOnlyHandler(context *fiber.Ctx) error {
+	if _, ok := context.Locals("scopes").([]string); ok {
+		return context.SendStatus(fiber.StatusForbidden)
+	}
 
 	return context.Next()
 }
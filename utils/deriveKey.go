@@ -0,0 +1,13 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// 由主金鑰衍生指定用途的子金鑰，不同用途不共用同一把金鑰
+func DeriveKey(masterKey []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, masterKey)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
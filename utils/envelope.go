@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+// 資料加密金鑰長度(AES-256)
+const dataKeyLength = 32
+
+// 信封加密：以隨機資料金鑰加密內容，再以金鑰加密金鑰包裝資料金鑰
+// 回傳包裝後的資料金鑰與密文，兩者皆以 nonce 開頭
+func SealEnvelope(keyEncryptionKey []byte, plaintext []byte, additionalData []byte) (wrappedKey []byte, ciphertext []byte, err error) {
+	dataKey := make([]byte, dataKeyLength)
+	if _, err = rand.Read(dataKey); err != nil {
+		return nil, nil, err
+	}
+
+	if ciphertext, err = sealGCM(dataKey, plaintext, additionalData); err != nil {
+		return nil, nil, err
+	}
+	if wrappedKey, err = sealGCM(keyEncryptionKey, dataKey, additionalData); err != nil {
+		return nil, nil, err
+	}
+
+	return wrappedKey, ciphertext, nil
+}
+
+// 解開信封加密的內容
+func OpenEnvelope(keyEncryptionKey []byte, wrappedKey []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	dataKey, err := openGCM(keyEncryptionKey, wrappedKey, additionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	return openGCM(dataKey, ciphertext, additionalData)
+}
+
+func sealGCM(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func openGCM(key []byte, sealed []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
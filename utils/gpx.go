@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// 將路線座標輸出為 GPX 軌跡
+func WriteGPX(writer io.Writer, name string, startTime time.Time, points [][2]float64) error {
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(writer, `<gpx version="1.1" creator="Jimandy" xmlns="http://www.topografix.com/GPX/1/1">`)
+	fmt.Fprintf(writer, "  <metadata><time>%s</time></metadata>\n", startTime.UTC().Format(time.RFC3339))
+	fmt.Fprint(writer, "  <trk><name>")
+	_ = xml.EscapeText(writer, []byte(name))
+	fmt.Fprintln(writer, "</name><trkseg>")
+	for _, point := range points {
+		fmt.Fprintf(writer, "    <trkpt lat=\"%.5f\" lon=\"%.5f\"/>\n", point[0], point[1])
+	}
+	_, err := fmt.Fprintln(writer, "  </trkseg></trk>\n</gpx>")
+
+	return err
+}
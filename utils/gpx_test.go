@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 預期的 GPX 輸出
+const goldenGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="Jimandy" xmlns="http://www.topografix.com/GPX/1/1">
+  <metadata><time>2018-02-16T14:52:54Z</time></metadata>
+  <trk><name>Run &lt;Hills&gt; &amp; &#34;Tempo&#34;</name><trkseg>
+    <trkpt lat="38.50000" lon="-120.20000"/>
+    <trkpt lat="40.70000" lon="-120.95000"/>
+    <trkpt lat="43.25200" lon="-126.45300"/>
+  </trkseg></trk>
+</gpx>
+`
+
+func TestWriteGPX(t *testing.T) {
+	startTime := time.Date(2018, 2, 16, 22, 52, 54, 0, time.FixedZone("Asia/Taipei", 8*60*60))
+	points := DecodePolyline("_p~iF~ps|U_ulLnnqC_mqNvxq`@")
+
+	var builder strings.Builder
+	if err := WriteGPX(&builder, `Run <Hills> & "Tempo"`, startTime, points); err != nil {
+		t.Fatal(err)
+	}
+
+	if builder.String() != goldenGPX {
+		t.Fatalf("gpx output:\n%s\nwant:\n%s", builder.String(), goldenGPX)
+	}
+}
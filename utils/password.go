@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密碼最短長度
+const MinPasswordLength = 8
+
+// 比對不存在帳號時使用的雜湊，讓回應時間與存在帳號一致
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// 產生密碼雜湊
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// 比對密碼與雜湊，雜湊為空時仍執行一次比對以避免時間差
+func CheckPassword(hash string, password string) bool {
+	if hash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,49 @@
+package utils
+
+// 解碼 Google Encoded Polyline，回傳 [緯度, 經度] 座標
+// 內容不完整或含有不合法的字元時，只回傳在此之前已完整解碼的座標
+func DecodePolyline(polyline string) [][2]float64 {
+	points := [][2]float64{}
+	var latitude, longitude int
+
+	for index := 0; index < len(polyline); {
+		var delta [2]int
+		for i := range delta {
+			value, next, ok := decodePolylineValue(polyline, index)
+			if !ok {
+				return points
+			}
+			delta[i], index = value, next
+		}
+
+		latitude += delta[0]
+		longitude += delta[1]
+		points = append(points, [2]float64{float64(latitude) / 1e5, float64(longitude) / 1e5})
+	}
+
+	return points
+}
+
+// 解碼一個有號數值，回傳數值與下一個數值的起始位置
+func decodePolylineValue(polyline string, index int) (int, int, bool) {
+	result, shift := 0, 0
+	for ; index < len(polyline); index++ {
+		value := int(polyline[index]) - 63
+		// 每個字元為 0 到 63，座標差值不會超過 32 bits
+		if value < 0 || value > 63 || shift > 30 {
+			return 0, index, false
+		}
+
+		result |= (value & 0x1f) << shift
+		shift += 5
+		if value < 0x20 {
+			if result&1 != 0 {
+				return ^(result >> 1), index + 1, true
+			}
+			return result >> 1, index + 1, true
+		}
+	}
+
+	// 數值未結束即已到結尾
+	return 0, index, false
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecodePolyline(t *testing.T) {
+	// Google Encoded Polyline Algorithm Format 文件的範例
+	reference := [][2]float64{{38.5, -120.2}, {40.7, -120.95}, {43.252, -126.453}}
+
+	tests := []struct {
+		name     string
+		polyline string
+		points   [][2]float64
+	}{
+		{"reference", "_p~iF~ps|U_ulLnnqC_mqNvxq`@", reference},
+		{"single point", "_p~iF~ps|U", reference[:1]},
+		{"empty", "", [][2]float64{}},
+		{"truncated longitude", "_p~iF~ps|U_ulL", reference[:1]},
+		{"truncated value", "_p~iF~ps|U_ulLnnq", reference[:1]},
+		{"only continuation", "~~~~", [][2]float64{}},
+		{"overlong value", "~~~~~~~~~~~~~~~~~~~~?", [][2]float64{}},
+		{"invalid character", "_p~iF~ps|U _ulLnnqC", reference[:1]},
+		{"non ascii", "_p~iF~ps|U\xff\xfe", reference[:1]},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			points := DecodePolyline(test.polyline)
+			if len(points) != len(test.points) {
+				t.Fatalf("points %v, want %v", points, test.points)
+			}
+			for i := range points {
+				if math.Abs(points[i][0]-test.points[i][0]) > 1e-9 || math.Abs(points[i][1]-test.points[i][1]) > 1e-9 {
+					t.Fatalf("point %d: %v, want %v", i, points[i], test.points[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 附錄 B 的 SHA1 金鑰
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	// RFC 6238 附錄 B 為 8 位數，6 位數驗證碼取其末 6 碼
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil || string(key) != "12345678901234567890" {
+		t.Fatalf("decode secret: %q, %v", key, err)
+	}
+
+	for _, test := range tests {
+		now := time.Unix(test.unix, 0)
+		if code := totpCode(key, TOTPStep(now)); code != test.code {
+			t.Errorf("time %d: code %s, want %s", test.unix, code, test.code)
+		}
+		if step, ok := ValidateTOTP(rfc6238Secret, test.code, now); !ok || step != TOTPStep(now) {
+			t.Errorf("time %d: validate step %d, %v", test.unix, step, ok)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	key, _ := totpEncoding.DecodeString(rfc6238Secret)
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+
+	tests := []struct {
+		name  string
+		step  int64
+		valid bool
+	}{
+		{"two steps behind", current - 2, false},
+		{"one step behind", current - 1, true},
+		{"current step", current, true},
+		{"one step ahead", current + 1, true},
+		{"two steps ahead", current + 2, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfc6238Secret, totpCode(key, test.step), now)
+			if ok != test.valid || (ok && step != test.step) {
+				t.Fatalf("step %d, valid %v", step, ok)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPRejectsMalformed(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"wrong code", rfc6238Secret, "000000"},
+		{"short code", rfc6238Secret, "28708"},
+		{"eight digits", rfc6238Secret, "94287082"},
+		{"invalid secret", "not base32!", "287082"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, ok := ValidateTOTP(test.secret, test.code, now); ok {
+				t.Fatal("accepted")
+			}
+		})
+	}
+}
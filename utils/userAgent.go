@@ -0,0 +1,53 @@
+package utils
+
+import "strings"
+
+// 依使用者代理字串判斷瀏覽器、作業系統與裝置類型
+func ParseUserAgent(userAgent string) (browser string, os string, device string) {
+	lower := strings.ToLower(userAgent)
+
+	switch {
+	case strings.Contains(lower, "edg/"):
+		browser = "Edge"
+	case strings.Contains(lower, "opr/") || strings.Contains(lower, "opera"):
+		browser = "Opera"
+	case strings.Contains(lower, "samsungbrowser"):
+		browser = "Samsung Internet"
+	case strings.Contains(lower, "firefox/") || strings.Contains(lower, "fxios"):
+		browser = "Firefox"
+	case strings.Contains(lower, "chrome/") || strings.Contains(lower, "crios"):
+		browser = "Chrome"
+	case strings.Contains(lower, "safari/"):
+		browser = "Safari"
+	default:
+		browser = "Unknown"
+	}
+
+	switch {
+	case strings.Contains(lower, "iphone") || strings.Contains(lower, "ipad") || strings.Contains(lower, "ipod"):
+		os = "iOS"
+	case strings.Contains(lower, "android"):
+		os = "Android"
+	case strings.Contains(lower, "windows"):
+		os = "Windows"
+	case strings.Contains(lower, "mac os x") || strings.Contains(lower, "macintosh"):
+		os = "macOS"
+	case strings.Contains(lower, "cros"):
+		os = "ChromeOS"
+	case strings.Contains(lower, "linux"):
+		os = "Linux"
+	default:
+		os = "Unknown"
+	}
+
+	switch {
+	case strings.Contains(lower, "ipad") || strings.Contains(lower, "tablet"):
+		device = "Tablet"
+	case strings.Contains(lower, "mobi") || strings.Contains(lower, "iphone") || strings.Contains(lower, "android"):
+		device = "Mobile"
+	default:
+		device = "Desktop"
+	}
+
+	return
+}